@@ -1,67 +1,191 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/borisjacquot/juno/internal/bot"
+	"github.com/borisjacquot/juno/internal/commands"
+	"github.com/borisjacquot/juno/internal/config"
 	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/database/memory"
+	"github.com/borisjacquot/juno/internal/lifecycle"
+	"github.com/borisjacquot/juno/internal/logging"
+	"github.com/borisjacquot/juno/internal/metrics"
+	"github.com/borisjacquot/juno/internal/overwatch"
+	"github.com/borisjacquot/juno/internal/transfer"
 	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
 )
 
+const usage = `Usage: bot [command]
+
+The configuration is read from the YAML or TOML file set in CONFIG_FILE, environment variables override it.
+
+Commands:
+  run                    start the bot (default)
+  migrate                create or update the database schema
+  sync-commands          synchronize the slash commands with Discord without connecting to the gateway
+  list-commands          print the definitions of the slash commands as JSON
+  lookup <battletag>     fetch a player from the Overwatch API (overwatch.api_url) and print it as JSON
+  db stats               print the number of rows of every table
+  backup [dir]           write a consistent snapshot of the SQLite database in dir (BACKUP_DIR or "backups" by default)
+  restore <file>         replace the SQLite database with a backup, the bot must be stopped
+  export <guild> [file]  write the registrations of a guild as CSV or JSON (from the extension, JSON on stdout by default)
+  import <guild> <file>  register every valid row of a CSV or JSON file in a guild and report invalid rows
+`
+
+// errUsage is returned by the commands called with invalid arguments, the usage is printed
+var errUsage = errors.New("invalid arguments")
+
 func main() {
+	command := "run"
+	args := []string{}
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+		args = os.Args[2:]
+	}
 
-	logger := setupLogging()
+	if command == "help" || command == "-h" || command == "--help" {
+		fmt.Print(usage)
+		return
+	}
 
-	logger.Info("Starting Juno bot...")
-	// load env vars
-	if err := godotenv.Load(); err != nil {
-		logger.Info("No .env file found, using system env vars instead")
+	// load env vars, they override the configuration file
+	envErr := godotenv.Load()
+
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
 	}
 
-	// setup env vars
-	token := os.Getenv("DISCORD_TOKEN")
-	if token == "" {
-		logger.Fatal("DISCORD_TOKEN is not set")
+	logger := logging.New(cfg.Log.Level, cfg.Log.Format, cfg.Log.Redact, cfg.Log.RedactKey)
+
+	// keep stdout for the output of the CLI commands
+	if command != "run" {
+		logger.SetOutput(os.Stderr)
 	}
 
-	overfastURL := os.Getenv("OVERFAST_API_URL")
-	if overfastURL == "" {
-		overfastURL = "https://overfast-api.tekrop.fr"
-		logger.WithField("url", overfastURL).Info("OVERFAST_API_URL is not set, using default")
+	if envErr != nil {
+		logger.Debug("No .env file found, using system env vars instead")
 	}
 
-	dbPath := os.Getenv("DATABASE_PATH")
-	if dbPath == "" {
-		dbPath = "data/overwatch-bot.db"
-		logger.WithField("path", dbPath).Info("DATABASE_PATH is not set, using default database path")
+	// the commands return their error instead of exiting so their deferred calls run
+	if err := run(cfg, logger, command, args); err != nil {
+		if errors.Is(err, errUsage) {
+			if err != errUsage {
+				fmt.Fprintf(os.Stderr, "%v\n\n", err)
+			}
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		logger.WithError(err).WithField("command", command).Error("Command failed")
+		os.Exit(1)
+	}
+}
+
+// run runs a command of the CLI
+func run(cfg *config.Config, logger *log.Logger, command string, args []string) error {
+	switch command {
+	case "run":
+		return runBot(cfg, logger)
+	case "migrate":
+		return migrate(cfg, logger)
+	case "sync-commands":
+		return syncCommands(cfg, logger)
+	case "list-commands":
+		return listCommands(cfg, logger)
+	case "lookup":
+		return lookup(cfg, logger, args)
+	case "db":
+		return dbCommand(cfg, logger, args)
+	case "backup":
+		return backup(cfg, logger, args)
+	case "restore":
+		return restore(cfg, logger, args)
+	case "export":
+		return exportRegistrations(cfg, logger, args)
+	case "import":
+		return importRegistrations(cfg, logger, args)
+	default:
+		return fmt.Errorf("unknown command %q (%w)", command, errUsage)
+	}
+}
+
+// runBot starts the bot and blocks until a stop signal is received
+func runBot(cfg *config.Config, logger *log.Logger) error {
+	logger.Info("Starting Juno bot...")
+
+	if err := cfg.RequireToken(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	db, err := database.New(dbPath, logger)
+	if cfg.Discord.OwnerID == "" {
+		logger.Info("OWNER_ID is not set, admin commands are disabled")
+	}
+
+	// commands are registered instantly in development guilds instead of globally
+	if len(cfg.Discord.DevGuildIDs) > 0 {
+		logger.WithField("guilds", cfg.Discord.DevGuildIDs).Info("Development guilds are set, registering commands in development guilds only")
+	}
+
+	db, err := openDatabase(cfg, logger)
 	if err != nil {
-		logger.WithError(err).Fatal("Failed to initialize database")
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
-	defer db.Close()
+	defer func() {
+		if err := db.Close(); err != nil {
+			logger.WithError(err).Error("Failed to close database")
+			return
+		}
+		logger.Info("Database closed")
+	}()
 
 	logger.WithFields(log.Fields{
-		"token":         token[:5] + "******",
-		"overfast_url":  overfastURL,
-		"database_path": dbPath,
-	}).Info("Environment variables loaded")
+		"token":        cfg.Discord.Token[:min(5, len(cfg.Discord.Token))] + "******",
+		"overfast_url": cfg.Overwatch.APIURL,
+		"database":     database.RedactDSN(cfg.Database.DSN),
+		"features":     fmt.Sprintf("%+v", cfg.Features),
+	}).Info("Configuration loaded")
+
+	// scheduled backups are enabled by setting BACKUP_INTERVAL
+	if scheduler := backupScheduler(cfg, db, logger); scheduler != nil {
+		scheduler.Start()
+		defer scheduler.Stop()
+	}
 
 	// init bot
-	b, err := bot.NewBot(token, overfastURL, db, logger)
+	m := metrics.New()
+	b, err := bot.NewBot(cfg, db, db, m, logger)
 	if err != nil {
-		logger.WithError(err).Fatal("Failed to create bot instance")
+		return fmt.Errorf("failed to create bot instance: %w", err)
+	}
+
+	// health checks and metrics are served when HTTP_ADDR is set
+	if cfg.HTTP.Addr != "" {
+		server := metrics.NewServer(cfg.HTTP.Addr, m, healthChecks(b, db), logger)
+		server.Start()
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := server.Stop(ctx); err != nil {
+				logger.WithError(err).Error("Failed to stop HTTP server")
+			}
+		}()
 	}
 
 	// start bot
 	if err := b.Start(); err != nil {
-		logger.WithError(err).Fatal("Failed to start bot")
+		return fmt.Errorf("failed to start bot: %w", err)
 	}
-	defer b.Stop()
 
 	logger.Info("Juno bot is started. Press CTRL+C to gracefully stop.")
 
@@ -71,30 +195,301 @@ func main() {
 	<-sc
 
 	logger.Info("Stop signal received, shutting down Juno bot...")
+
+	// a second signal stops the bot without waiting
+	go func() {
+		<-sc
+		logger.Warn("Second stop signal received, exiting immediately")
+		os.Exit(1)
+	}()
+
+	// the session is closed once the interactions in progress are done, then the deferred calls stop the HTTP server,
+	// the backup scheduler and close the database
+	if err := b.Shutdown(cfg.Shutdown.Timeout); err != nil {
+		logger.WithError(err).Warn("Bot did not shut down cleanly")
+	}
+	return nil
 }
 
-func setupLogging() *log.Logger {
-	// setup logs
-	logger := log.New()
-
-	logger.SetFormatter(&log.TextFormatter{
-		FullTimestamp:   true,
-		TimestampFormat: "2006-01-02 15:04:05",
-	})
-	logger.SetOutput(os.Stdout)
-
-	// handle loglevel
-	logLevel := os.Getenv("LOG_LEVEL")
-	switch logLevel {
-	case "debug":
-		logger.SetLevel(log.DebugLevel)
-	case "warn":
-		logger.SetLevel(log.WarnLevel)
-	case "error":
-		logger.SetLevel(log.ErrorLevel)
-	default:
-		logger.SetLevel(log.InfoLevel)
+// migrate creates or updates the database schema, it's also done when the bot starts unless DATABASE_AUTO_MIGRATE is off
+func migrate(cfg *config.Config, logger *log.Logger) error {
+	db, err := database.New(cfg.Database.DSN, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.Migrate(context.Background()); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	fmt.Println("Database schema is up to date")
+	return nil
+}
+
+// syncCommands synchronizes the slash commands like the bot does when it connects
+func syncCommands(cfg *config.Config, logger *log.Logger) error {
+	if err := cfg.RequireToken(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	db, err := openDatabase(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	b, err := bot.NewBot(cfg, db, db, metrics.New(), logger)
+	if err != nil {
+		return fmt.Errorf("failed to create bot instance: %w", err)
 	}
 
-	return logger
+	if err := b.SyncCommands(); err != nil {
+		return fmt.Errorf("failed to synchronize slash commands: %w", err)
+	}
+	return nil
+}
+
+// listCommands prints the definitions sent to Discord for every command
+func listCommands(cfg *config.Config, logger *log.Logger) error {
+	// the definitions don't depend on the stored data, so the database is left untouched
+	owClient := overwatch.NewClient(cfg.Overwatch.APIURL, cfg.Overwatch.Timeout, cfg.Overwatch.GameDataTTL, logger)
+	handler := commands.NewHandler(cfg, owClient, memory.New(), nil, lifecycle.NewGroup(logger), nil, nil, logger)
+
+	return printJSON(handler.ApplicationCommands())
+}
+
+// lookup fetches a player from the Overwatch API, to check the API is reachable without Discord
+func lookup(cfg *config.Config, logger *log.Logger, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	// accept both Player#1234 and the Player-1234 format of the API
+	battleTag := overwatch.ToDisplayFormat(args[0])
+	if !overwatch.IsValidBattleTag(battleTag) {
+		return fmt.Errorf("invalid BattleTag %q, expected Player#1234", args[0])
+	}
+
+	owClient := overwatch.NewClient(cfg.Overwatch.APIURL, cfg.Overwatch.Timeout, cfg.Overwatch.GameDataTTL, logger)
+
+	player, err := owClient.GetPlayer(context.Background(), overwatch.ToAPIFormat(battleTag))
+	if err != nil {
+		return fmt.Errorf("failed to look up player on %s: %w", cfg.Overwatch.APIURL, err)
+	}
+
+	return printJSON(player)
+}
+
+// dbCommand runs the database subcommands
+func dbCommand(cfg *config.Config, logger *log.Logger, args []string) error {
+	if len(args) != 1 || args[0] != "stats" {
+		return errUsage
+	}
+
+	db, err := openDatabase(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	stats, err := db.Stats()
+	if err != nil {
+		return fmt.Errorf("failed to get database stats: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Database\t%s (%s)\n", database.RedactDSN(cfg.Database.DSN), stats.Driver)
+	fmt.Fprintf(w, "Registrations\t%d\n", stats.Registrations)
+	fmt.Fprintf(w, "Registered users\t%d\n", stats.RegisteredUsers)
+	fmt.Fprintf(w, "Registered guilds\t%d\n", stats.RegisteredGuilds)
+	fmt.Fprintf(w, "Global links\t%d\n", stats.GlobalLinks)
+	fmt.Fprintf(w, "Guild settings\t%d\n", stats.GuildSettings)
+	fmt.Fprintf(w, "Command restrictions\t%d\n", stats.Restrictions)
+	fmt.Fprintf(w, "Command toggles\t%d\n", stats.Toggles)
+	return w.Flush()
+}
+
+// backup writes a snapshot of the database in the given directory
+func backup(cfg *config.Config, logger *log.Logger, args []string) error {
+	dir := cfg.Backup.Dir
+	if len(args) > 0 {
+		dir = args[0]
+	}
+
+	db, err := openDatabase(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	path, err := db.BackupToDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	fmt.Println(path)
+	return nil
+}
+
+// restore replaces the database with a backup file
+func restore(cfg *config.Config, logger *log.Logger, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	if err := database.Restore(args[0], cfg.Database.DSN, logger); err != nil {
+		return fmt.Errorf("failed to restore database: %w", err)
+	}
+	return nil
+}
+
+// exportRegistrations writes the registrations of a guild to a file or stdout
+func exportRegistrations(cfg *config.Config, logger *log.Logger, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+
+	guildID := args[0]
+	format := transfer.FormatJSON
+	if len(args) == 2 {
+		var err error
+		format, err = transfer.FormatFromFilename(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to export registrations: %w", err)
+		}
+	}
+
+	db, err := openDatabase(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	registrations, err := db.GetGuildRegistrations(context.Background(), guildID)
+	if err != nil {
+		return fmt.Errorf("failed to get guild registrations: %w", err)
+	}
+
+	// the file is only created once the registrations were read, a failure doesn't leave an empty export behind
+	out := os.Stdout
+	if len(args) == 2 {
+		out, err = os.Create(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to create export file: %w", err)
+		}
+		defer out.Close()
+	}
+
+	if err := transfer.Export(out, format, registrations); err != nil {
+		return fmt.Errorf("failed to export registrations: %w", err)
+	}
+
+	logger.WithFields(log.Fields{
+		"guild_id": guildID,
+		"count":    len(registrations),
+	}).Info("Registrations exported")
+	return nil
+}
+
+// importRegistrations registers every valid row of a file in a guild
+func importRegistrations(cfg *config.Config, logger *log.Logger, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	guildID, path := args[0], args[1]
+
+	format, err := transfer.FormatFromFilename(path)
+	if err != nil {
+		return fmt.Errorf("failed to import registrations: %w", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open import file: %w", err)
+	}
+	defer file.Close()
+
+	records, rowErrors, err := transfer.Parse(file, format)
+	if err != nil {
+		return fmt.Errorf("failed to read import file: %w", err)
+	}
+	for _, rowError := range rowErrors {
+		fmt.Fprintln(os.Stderr, rowError.Error())
+	}
+
+	db, err := openDatabase(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer db.Close()
+
+	imported, err := transfer.Import(context.Background(), db, guildID, records, logger)
+	if err != nil {
+		return fmt.Errorf("failed to import registrations: %w", err)
+	}
+
+	logger.WithFields(log.Fields{
+		"guild_id": guildID,
+		"imported": imported,
+		"skipped":  len(rowErrors),
+	}).Info("Registrations imported")
+	return nil
+}
+
+// openDatabase opens the configured database and migrates it unless auto migration is turned off
+func openDatabase(cfg *config.Config, logger *log.Logger) (*database.Database, error) {
+	db, err := database.New(cfg.Database.DSN, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Database.AutoMigrate {
+		if err := db.Migrate(context.Background()); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// healthChecks returns the readiness checks of the bot connection and of the database
+func healthChecks(b *bot.Bot, db database.Pinger) map[string]metrics.Check {
+	return map[string]metrics.Check{
+		"discord": func(ctx context.Context) error {
+			if b.Stopping() {
+				return errors.New("shutting down")
+			}
+			if shards := b.DisconnectedShards(); len(shards) > 0 {
+				return fmt.Errorf("shards %v not connected", shards)
+			}
+			return nil
+		},
+		"database": db.Ping,
+	}
+}
+
+// backupScheduler returns the scheduler configured by BACKUP_INTERVAL and BACKUP_KEEP, or nil if disabled
+func backupScheduler(cfg *config.Config, db *database.Database, logger *log.Logger) *database.BackupScheduler {
+	if cfg.Backup.Interval == 0 {
+		return nil
+	}
+
+	if db.Driver() != database.DriverSQLite {
+		logger.Warn("BACKUP_INTERVAL is set but scheduled backups are only supported for SQLite, ignoring")
+		return nil
+	}
+
+	return database.NewBackupScheduler(db, cfg.Backup.Dir, cfg.Backup.Interval, cfg.Backup.Keep, logger)
+}
+
+// printJSON writes value as indented JSON on stdout
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("failed to write JSON: %w", err)
+	}
+	return nil
 }
@@ -1,79 +1,184 @@
 package bot
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/borisjacquot/juno/internal/commands"
+	"github.com/borisjacquot/juno/internal/config"
 	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/interaction"
+	"github.com/borisjacquot/juno/internal/lifecycle"
+	"github.com/borisjacquot/juno/internal/logging"
 	"github.com/borisjacquot/juno/internal/overwatch"
+	"github.com/borisjacquot/juno/internal/presence"
 	"github.com/bwmarrin/discordgo"
 	log "github.com/sirupsen/logrus"
 )
 
+// maxGuildsPerPage is the maximum number of guilds Discord returns per request
+const maxGuildsPerPage = 200
+
 type Bot struct {
-	session    *discordgo.Session
-	owClient   *overwatch.Client
-	db         *database.Database
-	cmdHandler *commands.Handler
-	logger     *log.Logger
+	token       string
+	shardCount  int
+	gracePeriod time.Duration
+	owClient    *overwatch.Client
+	db          database.Store
+	cmdHandler  *commands.Handler
+	work        *lifecycle.Group
+	presence    *presence.Manager
+	gateway     GatewayRecorder
+	logger      *log.Logger
+
+	// shards holds a single shard until Start creates the others, the first one is also used for the REST API
+	mu     sync.RWMutex
+	shards []*shard
+
+	// stopCleanup stops the periodic cleanup of the guilds the bot was removed from, it's closed once by Shutdown
+	stopCleanup chan struct{}
+	stopOnce    sync.Once
 }
 
-// NewBot creates a new Bot instance
-func NewBot(token, overfastURL string, db *database.Database, logger *log.Logger) (*Bot, error) {
-	logger.Debug("Creating Discord session...")
+// GatewayRecorder records the connections of the shards to the Discord gateway
+type GatewayRecorder interface {
+	ObserveShards(count int)
+	ObserveReconnect(shard int)
+	ObserveShardConnected(shard int, connected bool)
+}
+
+// Recorder records the activity of the bot, the commands, the Overwatch API and the gateway
+type Recorder interface {
+	commands.MetricsRecorder
+	overwatch.Observer
+	GatewayRecorder
+}
+
+// NewBot creates a new Bot instance from a validated configuration, its activity is recorded with recorder
+// backups is used by /admin backup and can be nil when the database cannot be backed up
+func NewBot(cfg *config.Config, db database.Store, backups database.BackupStore, recorder Recorder, logger *log.Logger) (*Bot, error) {
+	logger.WithField("url", cfg.Overwatch.APIURL).Debug("Creating Overwatch client...")
+	owClient := overwatch.NewClient(cfg.Overwatch.APIURL, cfg.Overwatch.Timeout, cfg.Overwatch.GameDataTTL, logger)
+	owClient.SetObserver(recorder)
+
+	bot := &Bot{
+		token:       cfg.Discord.Token,
+		shardCount:  cfg.Discord.ShardCount,
+		gracePeriod: cfg.Database.RemovedGuildGracePeriod,
+		stopCleanup: make(chan struct{}),
+		owClient:    owClient,
+		db:          db,
+		work:        lifecycle.NewGroup(logger),
+		gateway:     recorder,
+		logger:      logger,
+	}
+
+	// the status shows live values and is set on every shard, the owner can change it with /admin presence
+	bot.presence = presence.NewManager(cfg.Presence.Templates, cfg.Presence.Interval, bot.presenceValues, bot.setStatus, logger)
 
-	session, err := discordgo.New("Bot " + token)
+	cmdHandler := commands.NewHandler(cfg, owClient, db, backups, bot.work, bot.presence, recorder, logger)
+	bot.cmdHandler = cmdHandler
+
+	logger.Debug("Creating Discord session...")
+	session, err := bot.newSession()
 	if err != nil {
 		return nil, err
 	}
+	bot.shards = []*shard{{session: session}}
 
-	logger.WithField("url", overfastURL).Debug("Creating Overwatch client...")
-	owClient := overwatch.NewClient(overfastURL, logger)
+	// the guilds are spread across the shards, the commands of all of them are synchronized after a toggle changed
+	cmdHandler.SetGuildSource(bot.guildIDs)
 
-	cmdHandler := commands.NewHandler(owClient, db, logger)
+	logger.Debug("Bot instance created successfully")
+	return bot, nil
+}
 
-	bot := &Bot{
-		session:    session,
-		owClient:   owClient,
-		db:         db,
-		cmdHandler: cmdHandler,
-		logger:     logger,
+// newSession creates a Discord session with the handlers of the bot, every shard has its own
+func (b *Bot) newSession() (*discordgo.Session, error) {
+	session, err := discordgo.New("Bot " + b.token)
+	if err != nil {
+		return nil, err
 	}
 
 	// record handlers
-	session.AddHandler(bot.interactionCreate)
-	session.AddHandler(bot.ready)
+	session.AddHandler(b.connect)
+	session.AddHandler(b.disconnect)
+	session.AddHandler(b.interactionCreate)
+	session.AddHandler(b.ready)
+	session.AddHandler(b.guildCreate)
+	session.AddHandler(b.guildDelete)
 
 	// set intents
 	session.Identify.Intents = discordgo.IntentsGuilds
 
-	logger.Debug("Bot instance created successfully")
-	return bot, nil
+	return session, nil
 }
 
-// Start opens the Discord session and starts the bot
-func (b *Bot) Start() error {
-	b.logger.Info("Opening WebSocket connection to Discord...")
-	return b.session.Open()
+// Shutdown stops accepting interactions and waits for the interactions and background jobs in progress, for timeout
+// at most, then closes the Discord session
+// The work still running when the deadline expires is cancelled and an error is returned, the session is closed anyway
+// The slash commands stay registered, they are synchronized again on the next start
+func (b *Bot) Shutdown(timeout time.Duration) error {
+	b.logger.WithFields(log.Fields{
+		"in_flight": b.work.Running(),
+		"timeout":   timeout,
+	}).Info("Waiting for interactions and background jobs to finish...")
+
+	b.stopOnce.Do(func() {
+		close(b.stopCleanup)
+		b.presence.Stop()
+	})
+	err := b.work.Shutdown(timeout)
+	if err == nil {
+		b.logger.Info("Interactions and background jobs finished")
+	}
+
+	b.logger.Info("Closing WebSocket connections to Discord...")
+	for _, shard := range b.allShards() {
+		if closeErr := shard.session.Close(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to close Discord session of shard %d: %w", shard.session.ShardID, closeErr))
+		}
+	}
+	return err
 }
 
-// Stop closes the Discord session and stops the bot
-func (b *Bot) Stop() error {
-	b.logger.Info("Deleting slash commands...")
-	registeredCommands, err := b.session.ApplicationCommands(b.session.State.User.ID, "")
+// Stopping reports whether the bot is shutting down and refusing new interactions
+func (b *Bot) Stopping() bool {
+	return b.work.Stopping()
+}
+
+// SyncCommands synchronizes the slash commands through the REST API, without connecting to the gateway
+// The bot user and its guilds, normally received with the ready event, are fetched instead
+func (b *Bot) SyncCommands() error {
+	session := b.allShards()[0].session
+
+	user, err := session.User("@me")
 	if err != nil {
-		b.logger.WithError(err).Error("Failed to fetch registered commands")
-	} else {
-		for _, cmd := range registeredCommands {
-			err := b.session.ApplicationCommandDelete(b.session.State.User.ID, "", cmd.ID)
-			if err != nil {
-				b.logger.WithError(err).WithField("command", cmd.Name).Error("Failed to delete command")
-			} else {
-				b.logger.WithField("command", cmd.Name).Debug("Deleted command")
+		return fmt.Errorf("failed to fetch bot user: %w", err)
+	}
+	session.State.User = user
+
+	after := ""
+	for {
+		guilds, err := session.UserGuilds(maxGuildsPerPage, "", after, false)
+		if err != nil {
+			return fmt.Errorf("failed to fetch guilds: %w", err)
+		}
+		for _, guild := range guilds {
+			if err := session.State.GuildAdd(&discordgo.Guild{ID: guild.ID, Name: guild.Name}); err != nil {
+				return fmt.Errorf("failed to add guild to state: %w", err)
 			}
 		}
+		if len(guilds) < maxGuildsPerPage {
+			break
+		}
+		after = guilds[len(guilds)-1].ID
 	}
 
-	b.logger.Info("Closing WebSocket connection to Discord...")
-	return b.session.Close()
+	return b.cmdHandler.SyncAllCommands(session)
 }
 
 // ready is called when the bot has connected to Discord and is ready to receive events
@@ -81,35 +186,77 @@ func (b *Bot) ready(s *discordgo.Session, event *discordgo.Ready) {
 	b.logger.WithFields(log.Fields{
 		"username": s.State.User.Username,
 		"id":       s.State.User.ID,
+		"shard":    s.ShardID,
 		"guilds":   len(event.Guilds),
-	}).Info("Bot is ready")
+	}).Info("Shard is ready")
 
-	// register slash commands
-	if err := b.cmdHandler.RegisterSlashCommands(s); err != nil {
-		b.logger.WithError(err).Error("Failed to register slash commands")
-		return
+	// synchronize slash commands, ready is also received after reconnections but nothing is sent if they didn't change
+	// the global commands are the same for every shard, only the first one synchronizes them
+	if s.ShardID == 0 {
+		if err := b.cmdHandler.SyncCommands(s); err != nil {
+			b.logger.WithError(err).Error("Failed to synchronize slash commands")
+			return
+		}
 	}
 
-	// set the bot's presence
-	err := s.UpdateGameStatus(0, "Overwatch | /help")
-	if err != nil {
-		b.logger.WithError(err).Error("Failed to set bot presence")
+	// the presence is lost when the session is identified again, ready is received every time
+	status := b.presence.Status(context.Background())
+	if err := s.UpdateGameStatus(0, status); err != nil {
+		b.logger.WithError(err).WithField("shard", s.ShardID).Error("Failed to set bot presence")
 	}
 }
 
 // interactionCreate is called when a new interaction is created (e.g. a slash command is used)
 func (b *Bot) interactionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	if i.Type != discordgo.InteractionApplicationCommand {
+	// interactions received while shutting down are refused, the ones in progress are waited for
+	ctx, done, ok := b.work.Enter()
+	if !ok {
+		b.refuse(s, i)
 		return
 	}
+	defer done()
 
-	b.logger.WithFields(log.Fields{
-		"user":    i.Member.User.Username,
-		"command": i.ApplicationCommandData().Name,
-		"options": i.ApplicationCommandData().Options,
-		"channel": i.ChannelID,
-		"guild":   i.GuildID,
-	}).Debug("Received interaction")
-
-	b.cmdHandler.HandleSlashCommand(s, i)
+	// every log line about the interaction, down to the database and the Overwatch API, gets the same correlation ID
+	ctx = logging.WithCorrelationID(ctx, logging.NewCorrelationID())
+
+	// a panic must not crash the bot, the user gets an incident ID instead
+	defer b.cmdHandler.Recover(ctx, s, i)
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		b.logger.WithContext(ctx).WithFields(log.Fields{
+			"user":    interaction.User(i.Interaction).Username,
+			"command": i.ApplicationCommandData().Name,
+			"options": i.ApplicationCommandData().Options,
+			"channel": i.ChannelID,
+			"guild":   i.GuildID,
+		}).Debug("Received interaction")
+
+		b.cmdHandler.HandleSlashCommand(ctx, s, i)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		b.cmdHandler.HandleAutocomplete(ctx, s, i)
+	case discordgo.InteractionMessageComponent:
+		b.cmdHandler.HandleComponent(ctx, s, i)
+	case discordgo.InteractionModalSubmit:
+		b.cmdHandler.HandleModalSubmit(ctx, s, i)
+	}
+}
+
+// refuse answers an interaction received while the bot is shutting down
+func (b *Bot) refuse(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	// autocomplete interactions can only be answered with suggestions, Discord shows an error when they time out
+	if i.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "⏳ The bot is restarting, please try again in a moment.",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		b.logger.WithError(err).Debug("Failed to refuse interaction during shutdown")
+	}
 }
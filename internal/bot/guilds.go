@@ -0,0 +1,215 @@
+package bot
+
+import (
+	"context"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// joinedRecently is how recent the join date of a guild must be for its guild create event to mean the bot joined it
+// Guild create events are also sent at startup and when a guild becomes available again after an outage
+const joinedRecently = time.Minute
+
+// cleanupInterval is how often the guilds the bot was removed from are looked for
+const cleanupInterval = time.Hour
+
+// guildCreate is called when a guild becomes available, at startup or when the bot joins it
+func (b *Bot) guildCreate(s *discordgo.Session, event *discordgo.GuildCreate) {
+	ctx, done, ok := b.work.Enter()
+	if !ok {
+		return
+	}
+	defer done()
+
+	entry := b.logger.WithFields(log.Fields{
+		"guild_id": event.ID,
+		"shard":    s.ShardID,
+	})
+
+	joined := time.Since(event.JoinedAt) <= joinedRecently
+
+	// commands toggled per guild and the commands of development guilds are registered in the guild
+	if err := b.cmdHandler.SyncGuildCommands(s, event.ID, joined); err != nil {
+		entry.WithError(err).Error("Failed to synchronize guild slash commands")
+	}
+
+	settings, err := b.db.GetGuildSettings(ctx, event.ID)
+	if err != nil {
+		entry.WithError(err).Error("Failed to get guild settings from database")
+		return
+	}
+
+	// the default settings are saved the first time the bot sees the guild, a guild the bot was added back to
+	// during the grace period keeps its registrations
+	if settings.ID == 0 || settings.RemovedAt != nil {
+		readded := settings.RemovedAt != nil
+		settings.RemovedAt = nil
+		if err := b.db.SaveGuildSettings(ctx, settings); err != nil {
+			entry.WithError(err).Error("Failed to save guild settings in database")
+			return
+		}
+		if readded {
+			entry.Info("Bot was added back to guild, its registrations are kept")
+		}
+	}
+
+	if !joined {
+		return
+	}
+
+	entry.WithFields(log.Fields{
+		"name":    event.Name,
+		"members": event.MemberCount,
+	}).Info("Bot joined guild")
+
+	b.onboard(s, event.Guild, settings)
+}
+
+// guildDelete is called when the bot is removed from a guild or when a guild becomes unavailable
+func (b *Bot) guildDelete(s *discordgo.Session, event *discordgo.GuildDelete) {
+	entry := b.logger.WithFields(log.Fields{
+		"guild_id": event.ID,
+		"shard":    s.ShardID,
+	})
+
+	// guilds are unavailable during Discord outages, the bot is still a member
+	if event.Unavailable {
+		entry.Warn("Guild became unavailable")
+		return
+	}
+
+	ctx, done, ok := b.work.Enter()
+	if !ok {
+		return
+	}
+	defer done()
+
+	settings, err := b.db.GetGuildSettings(ctx, event.ID)
+	if err != nil {
+		entry.WithError(err).Error("Failed to get guild settings from database")
+		return
+	}
+
+	// the registrations are deleted by the cleanup once the grace period is over, unless the bot is added back
+	now := time.Now()
+	settings.RemovedAt = &now
+	if err := b.db.SaveGuildSettings(ctx, settings); err != nil {
+		entry.WithError(err).Error("Failed to save guild settings in database")
+		return
+	}
+
+	if event.BeforeDelete != nil {
+		entry = entry.WithField("name", event.BeforeDelete.Name)
+	}
+	if b.gracePeriod == 0 {
+		entry.Info("Bot was removed from guild, its registrations are kept")
+		return
+	}
+	entry.WithField("grace_period", b.gracePeriod).Info("Bot was removed from guild, its registrations will be deleted after the grace period")
+}
+
+// onboard sends the welcome message of the bot to the system channel of a guild it joined
+func (b *Bot) onboard(s *discordgo.Session, guild *discordgo.Guild, settings *database.GuildSettings) {
+	entry := b.logger.WithField("guild_id", guild.ID)
+
+	if guild.SystemChannelID == "" {
+		entry.Debug("Guild has no system channel, skipping welcome message")
+		return
+	}
+
+	description := settings.WelcomeMessage
+	if description == "" {
+		description = "Juno brings Overwatch profiles, heroes and maps to your server."
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "👋 Thanks for adding Juno!",
+		Description: description,
+		Color:       0xD183C9,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "📝 Link your account",
+				Value:  "Use `/register` with your BattleTag so the other commands know who you are.",
+				Inline: false,
+			},
+			{
+				Name:   "📊 Look up stats",
+				Value:  "Use `/profile` for competitive ranks and stats, `/hero` and `/map` for game data.",
+				Inline: false,
+			},
+			{
+				Name:   "⚙️ Configure",
+				Value:  "Admins can set the welcome message with `/config` and restrict commands with `/permissions`.",
+				Inline: false,
+			},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Use /help to see every command",
+		},
+	}
+
+	// the bot may not be allowed to post in the system channel, the guild just doesn't get the message
+	if _, err := s.ChannelMessageSendEmbed(guild.SystemChannelID, embed); err != nil {
+		entry.WithError(err).Warn("Failed to send welcome message")
+	}
+}
+
+// startCleanup deletes the registrations of the guilds the bot was removed from once the grace period is over,
+// it runs when the bot starts and then every cleanupInterval until Shutdown is called
+func (b *Bot) startCleanup() {
+	if b.gracePeriod == 0 {
+		b.logger.Debug("Removed guild grace period is zero, registrations of removed guilds are kept")
+		return
+	}
+
+	b.logger.WithField("grace_period", b.gracePeriod).Info("Starting cleanup of removed guilds")
+
+	go func() {
+		ticker := time.NewTicker(cleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			b.work.Go("removed guilds cleanup", b.cleanupRemovedGuilds)
+
+			select {
+			case <-ticker.C:
+			case <-b.stopCleanup:
+				return
+			}
+		}
+	}()
+}
+
+// cleanupRemovedGuilds deletes the registrations of the guilds the bot was removed from before the grace period
+// Their settings are kept, the removal date is cleared once the registrations are deleted
+func (b *Bot) cleanupRemovedGuilds(ctx context.Context) {
+	guilds, err := b.db.GetRemovedGuilds(ctx, time.Now().Add(-b.gracePeriod))
+	if err != nil {
+		b.logger.WithError(err).Error("Failed to get removed guilds from database")
+		return
+	}
+
+	for _, settings := range guilds {
+		entry := b.logger.WithFields(log.Fields{
+			"guild_id":   settings.GuildID,
+			"removed_at": settings.RemovedAt,
+		})
+
+		removed, err := b.db.DeleteGuildRegistrations(ctx, settings.GuildID)
+		if err != nil {
+			entry.WithError(err).Error("Failed to delete registrations of removed guild")
+			continue
+		}
+
+		settings.RemovedAt = nil
+		if err := b.db.SaveGuildSettings(ctx, &settings); err != nil {
+			entry.WithError(err).Error("Failed to save guild settings in database")
+			continue
+		}
+
+		entry.WithField("registrations", removed).Info("Deleted registrations of removed guild")
+	}
+}
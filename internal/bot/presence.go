@@ -0,0 +1,36 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/borisjacquot/juno/internal/presence"
+)
+
+// presenceValues returns the live values of the presence templates
+func (b *Bot) presenceValues(ctx context.Context) presence.Values {
+	values := presence.Values{
+		Guilds:  len(b.guildIDs()),
+		Players: -1,
+		Season:  b.owClient.CurrentSeason(),
+	}
+
+	players, err := b.db.GetUserStats(ctx)
+	if err != nil {
+		b.logger.WithError(err).Warn("Failed to count registered players for the presence")
+		return values
+	}
+	values.Players = players
+	return values
+}
+
+// setStatus sets the status of every connected shard, the others get it when they are ready
+func (b *Bot) setStatus(status string) {
+	for id, shard := range b.allShards() {
+		if !shard.connected.Load() {
+			continue
+		}
+		if err := shard.session.UpdateGameStatus(0, status); err != nil {
+			b.logger.WithError(err).WithField("shard", id).Error("Failed to set bot presence")
+		}
+	}
+}
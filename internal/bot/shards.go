@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// identifyInterval is the time Discord requires between two groups of shards identifying
+const identifyInterval = 5 * time.Second
+
+// shard is one of the WebSocket connections to Discord, each one receives the events of a part of the guilds
+// The shards share the command handler, the database and the Overwatch client of the bot
+type shard struct {
+	session       *discordgo.Session
+	connected     atomic.Bool
+	everConnected atomic.Bool
+}
+
+// Start opens a WebSocket connection to Discord per shard
+// The number of shards is the configured one, or the one recommended by Discord when it's 0
+func (b *Bot) Start() error {
+	first := b.allShards()[0].session
+
+	gateway, err := first.GatewayBot()
+	if err != nil {
+		return fmt.Errorf("failed to fetch gateway information: %w", err)
+	}
+
+	count := b.shardCount
+	if count == 0 {
+		count = max(gateway.Shards, 1)
+	}
+	// up to max_concurrency shards can identify at the same time, then the next ones must wait
+	concurrency := max(gateway.SessionStartLimit.MaxConcurrency, 1)
+
+	shards := []*shard{{session: first}}
+	for len(shards) < count {
+		session, err := b.newSession()
+		if err != nil {
+			return fmt.Errorf("failed to create session of shard %d: %w", len(shards), err)
+		}
+		// the shards use the same token, they share its rate limits
+		session.Ratelimiter = first.Ratelimiter
+		shards = append(shards, &shard{session: session})
+	}
+	for id, shard := range shards {
+		shard.session.ShardID = id
+		shard.session.ShardCount = count
+	}
+
+	b.mu.Lock()
+	b.shards = shards
+	b.mu.Unlock()
+	b.gateway.ObserveShards(count)
+
+	b.logger.WithFields(log.Fields{
+		"shards":          count,
+		"recommended":     gateway.Shards,
+		"max_concurrency": concurrency,
+	}).Info("Opening WebSocket connections to Discord...")
+
+	for id, shard := range shards {
+		if id > 0 && id%concurrency == 0 {
+			time.Sleep(identifyInterval)
+		}
+		b.logger.WithField("shard", id).Debug("Opening shard...")
+		if err := shard.session.Open(); err != nil {
+			err = fmt.Errorf("failed to open shard %d: %w", id, err)
+			// close the shards already opened, the last one first
+			for opened := id - 1; opened >= 0; opened-- {
+				if closeErr := shards[opened].session.Close(); closeErr != nil {
+					err = errors.Join(err, fmt.Errorf("failed to close Discord session of shard %d: %w", opened, closeErr))
+				}
+			}
+			return err
+		}
+	}
+
+	b.startCleanup()
+	b.presence.Start(b.work)
+	return nil
+}
+
+// DisconnectedShards returns the shards whose WebSocket connection to Discord isn't established
+func (b *Bot) DisconnectedShards() []int {
+	disconnected := []int{}
+	for id, shard := range b.allShards() {
+		if !shard.connected.Load() {
+			disconnected = append(disconnected, id)
+		}
+	}
+	return disconnected
+}
+
+// allShards returns the shards of the bot, the slice must not be modified
+func (b *Bot) allShards() []*shard {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.shards
+}
+
+// shardOf returns the shard of a session
+func (b *Bot) shardOf(s *discordgo.Session) *shard {
+	return b.allShards()[s.ShardID]
+}
+
+// guildIDs returns the IDs of the guilds of every shard
+func (b *Bot) guildIDs() []string {
+	guildIDs := []string{}
+	for _, shard := range b.allShards() {
+		state := shard.session.State
+		state.RLock()
+		for _, guild := range state.Guilds {
+			guildIDs = append(guildIDs, guild.ID)
+		}
+		state.RUnlock()
+	}
+	return guildIDs
+}
+
+// connect is called every time the WebSocket connection of a shard is established
+func (b *Bot) connect(s *discordgo.Session, event *discordgo.Connect) {
+	shard := b.shardOf(s)
+	shard.connected.Store(true)
+	b.gateway.ObserveShardConnected(s.ShardID, true)
+
+	if shard.everConnected.Swap(true) {
+		b.logger.WithField("shard", s.ShardID).Info("Shard reconnected to Discord")
+		b.gateway.ObserveReconnect(s.ShardID)
+		return
+	}
+	b.logger.WithField("shard", s.ShardID).Debug("Shard connected to Discord")
+}
+
+// disconnect is called when the WebSocket connection of a shard is lost or closed
+func (b *Bot) disconnect(s *discordgo.Session, event *discordgo.Disconnect) {
+	b.shardOf(s).connected.Store(false)
+	b.gateway.ObserveShardConnected(s.ShardID, false)
+	b.logger.WithField("shard", s.ShardID).Warn("Shard disconnected from Discord")
+}
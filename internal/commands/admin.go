@@ -0,0 +1,512 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/interaction"
+	"github.com/borisjacquot/juno/internal/lifecycle"
+	"github.com/borisjacquot/juno/internal/presence"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxUploadSize is the largest file a bot can upload to Discord without boosts
+const maxUploadSize = 10 * 1024 * 1024
+
+// maxMessageLength is the maximum length of the content of a Discord message
+const maxMessageLength = 2000
+
+// AdminCommand groups the commands reserved to the owner of the bot
+type AdminCommand struct {
+	registry     *Registry
+	backups      database.BackupStore
+	features     database.FeatureStore
+	syncCommands func(s *discordgo.Session, guildID, command string) error
+	jobs         *lifecycle.Group
+	presence     PresenceManager
+	ownerID      string
+	logger       *log.Logger
+}
+
+// PresenceManager changes the status of the bot at runtime, the changes are shown right away
+type PresenceManager interface {
+	// Templates returns the templates in the order they are shown
+	Templates() []string
+
+	// AddTemplate adds a template at the end of the rotation, returns an error if it's invalid
+	AddTemplate(ctx context.Context, template string) error
+
+	// RemoveTemplate removes the template at a position of the rotation, starting at 1, and returns it
+	RemoveTemplate(ctx context.Context, position int) (string, error)
+
+	// Reset restores the configured templates
+	Reset(ctx context.Context)
+}
+
+// NewAdminCommand creates the admin command, syncCommands is called with the guild and the command whose toggle changed
+// It runs in the background, tracked by jobs so a shutdown waits for it
+func NewAdminCommand(registry *Registry, backups database.BackupStore, features database.FeatureStore, syncCommands func(s *discordgo.Session, guildID, command string) error, jobs *lifecycle.Group, presence PresenceManager, ownerID string, logger *log.Logger) *AdminCommand {
+	return &AdminCommand{
+		registry:     registry,
+		backups:      backups,
+		features:     features,
+		syncCommands: syncCommands,
+		jobs:         jobs,
+		presence:     presence,
+		ownerID:      ownerID,
+		logger:       logger,
+	}
+}
+
+func (c *AdminCommand) Name() string {
+	return "admin"
+}
+
+func (c *AdminCommand) Description() string {
+	return "Bot administration, reserved to the bot owner"
+}
+
+func (c *AdminCommand) Category() string {
+	return "Admin"
+}
+
+func (c *AdminCommand) GuildOnly() bool {
+	return true
+}
+
+func (c *AdminCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	return ExecuteSubcommand(ctx, c, s, i)
+}
+
+func (c *AdminCommand) Subcommands() []*Subcommand {
+	return []*Subcommand{
+		{
+			Name:        "backup",
+			Description: "Take a snapshot of the database and upload it",
+			Handler:     c.ownerOnly(c.backup),
+		},
+	}
+}
+
+func (c *AdminCommand) SubcommandGroups() []*SubcommandGroup {
+	commandOption := &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         "command",
+		Description:  "The command (e.g. profile)",
+		Required:     true,
+		Autocomplete: true,
+	}
+	guildOption := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "guild",
+		Description: "ID of the server, every server by default",
+		Required:    false,
+	}
+	firstPosition := 1.0
+
+	return []*SubcommandGroup{
+		{
+			Name:        "feature",
+			Description: "Register or hide commands in specific servers",
+			Subcommands: []*Subcommand{
+				{
+					Name:        "enable",
+					Description: "Register a command in a server",
+					Options:     []*discordgo.ApplicationCommandOption{commandOption, guildOption},
+					Handler:     c.ownerOnly(c.toggleFeature(true)),
+				},
+				{
+					Name:        "disable",
+					Description: "Hide a command in a server",
+					Options:     []*discordgo.ApplicationCommandOption{commandOption, guildOption},
+					Handler:     c.ownerOnly(c.toggleFeature(false)),
+				},
+				{
+					Name:        "reset",
+					Description: "Remove the toggle of a command in a server",
+					Options:     []*discordgo.ApplicationCommandOption{commandOption, guildOption},
+					Handler:     c.ownerOnly(c.resetFeature),
+				},
+				{
+					Name:        "list",
+					Description: "List the commands enabled or disabled per server",
+					Handler:     c.ownerOnly(c.listFeatures),
+				},
+			},
+		},
+		{
+			Name:        "presence",
+			Description: "Change the status of the bot until it restarts",
+			Subcommands: []*Subcommand{
+				{
+					Name:        "list",
+					Description: "List the statuses shown in turn",
+					Handler:     c.ownerOnly(c.withPresence(c.listPresence)),
+				},
+				{
+					Name:        "add",
+					Description: "Add a status to the rotation",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "template",
+							Description: "The status, {guilds}, {players} and {season} are replaced by live values",
+							Required:    true,
+							MaxLength:   presence.MaxLength,
+						},
+					},
+					Handler: c.ownerOnly(c.withPresence(c.addPresence)),
+				},
+				{
+					Name:        "remove",
+					Description: "Remove a status from the rotation",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "position",
+							Description: "Position of the status, as shown by /admin presence list",
+							Required:    true,
+							MinValue:    &firstPosition,
+						},
+					},
+					Handler: c.ownerOnly(c.withPresence(c.removePresence)),
+				},
+				{
+					Name:        "reset",
+					Description: "Restore the statuses of the configuration",
+					Handler:     c.ownerOnly(c.withPresence(c.resetPresence)),
+				},
+			},
+		},
+	}
+}
+
+// ownerOnly rejects the interaction unless it comes from the bot owner
+func (c *AdminCommand) ownerOnly(handler SubcommandHandler) SubcommandHandler {
+	return func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+		if c.ownerID == "" || interaction.User(i.Interaction).ID != c.ownerID {
+			c.logger.WithContext(ctx).WithField("user", interaction.User(i.Interaction).Username).Warn("Non-owner tried to use admin command")
+			return c.respond(s, i, "❌ This command is reserved to the bot owner.")
+		}
+		return handler(ctx, s, i, options)
+	}
+}
+
+// backup takes a snapshot of the database and uploads it to the owner
+func (c *AdminCommand) backup(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, _ []*discordgo.ApplicationCommandInteractionDataOption) error {
+	if c.backups == nil {
+		return c.respond(s, i, "❌ This database cannot be backed up.")
+	}
+
+	// a backup can take a while, acknowledge the command first
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "juno-backup-")
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to create temporary backup directory")
+		return c.editResponse(s, i, "❌ Failed to back up the database.")
+	}
+	defer os.RemoveAll(dir)
+
+	path, err := c.backups.BackupToDir(dir)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to back up database")
+		return c.editResponse(s, i, fmt.Sprintf("❌ Failed to back up the database: %v", err))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to open database backup")
+		return c.editResponse(s, i, "❌ Failed to read the database backup.")
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() > maxUploadSize {
+		return c.editResponse(s, i, fmt.Sprintf("❌ The backup is too large to be uploaded (%d MiB). Use `bot backup` on the host instead.", info.Size()/1024/1024))
+	}
+
+	c.logger.WithContext(ctx).WithFields(log.Fields{
+		"user": interaction.User(i.Interaction).Username,
+		"size": info.Size(),
+	}).Info("Uploading database backup")
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: stringPtr("✅ Database backup"),
+		Files: []*discordgo.File{
+			{
+				Name:        filepath.Base(path),
+				ContentType: "application/vnd.sqlite3",
+				Reader:      file,
+			},
+		},
+	})
+	return err
+}
+
+// toggleFeature returns the handler enabling or disabling a command in a guild
+func (c *AdminCommand) toggleFeature(enabled bool) SubcommandHandler {
+	return func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+		cmd, guildID, err := c.featureOptions(options)
+		if err != nil {
+			return c.respond(s, i, fmt.Sprintf("❌ %v", err))
+		}
+
+		if err := c.features.SetCommandToggle(ctx, guildID, strings.ToLower(cmd.Name()), enabled); err != nil {
+			c.logger.WithContext(ctx).WithError(err).Error("Failed to save command toggle")
+			return c.respond(s, i, "❌ Failed to save the toggle. Please try again later.")
+		}
+
+		c.logger.WithContext(ctx).WithFields(log.Fields{
+			"user":     interaction.User(i.Interaction).Username,
+			"command":  cmd.Name(),
+			"guild_id": guildID,
+			"enabled":  enabled,
+		}).Info("Command toggle changed")
+
+		state := "disabled"
+		if enabled {
+			state = "enabled"
+		}
+		return c.syncFeatures(s, i, guildID, cmd, fmt.Sprintf("✅ **%s** is now %s in %s.", commandLabel(cmd), state, guildLabel(guildID)))
+	}
+}
+
+// resetFeature removes the toggle of a command in a guild
+func (c *AdminCommand) resetFeature(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	cmd, guildID, err := c.featureOptions(options)
+	if err != nil {
+		return c.respond(s, i, fmt.Sprintf("❌ %v", err))
+	}
+
+	err = c.features.RemoveCommandToggle(ctx, guildID, strings.ToLower(cmd.Name()))
+	if errors.Is(err, database.ErrNotFound) {
+		return c.respond(s, i, fmt.Sprintf("❌ **%s** isn't toggled in %s.", commandLabel(cmd), guildLabel(guildID)))
+	}
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to remove command toggle")
+		return c.respond(s, i, "❌ Failed to remove the toggle. Please try again later.")
+	}
+
+	c.logger.WithContext(ctx).WithFields(log.Fields{
+		"user":     interaction.User(i.Interaction).Username,
+		"command":  cmd.Name(),
+		"guild_id": guildID,
+	}).Info("Command toggle removed")
+
+	return c.syncFeatures(s, i, guildID, cmd, fmt.Sprintf("✅ **%s** uses the default again in %s.", commandLabel(cmd), guildLabel(guildID)))
+}
+
+// listFeatures shows the command toggles, as many as fit in a message
+func (c *AdminCommand) listFeatures(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, _ []*discordgo.ApplicationCommandInteractionDataOption) error {
+	toggles, err := c.features.GetCommandToggles(ctx)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to get command toggles from database")
+		return c.respond(s, i, "❌ Failed to retrieve the toggles.")
+	}
+
+	if len(toggles) == 0 {
+		return c.respond(s, i, "Every command is enabled in every server.")
+	}
+
+	var list strings.Builder
+	for n, toggle := range toggles {
+		state := "❌"
+		if toggle.Enabled {
+			state = "✅"
+		}
+		line := fmt.Sprintf("%s `%s` in %s\n", state, toggle.Command, guildLabel(toggle.GuildID))
+		// keep room for the line counting the toggles left out
+		if list.Len()+len(line) > maxMessageLength-50 {
+			list.WriteString(fmt.Sprintf("… and %d more\n", len(toggles)-n))
+			break
+		}
+		list.WriteString(line)
+	}
+
+	return c.respond(s, i, list.String())
+}
+
+// withPresence rejects the interaction when the status of the bot can't be changed
+func (c *AdminCommand) withPresence(handler SubcommandHandler) SubcommandHandler {
+	return func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+		if c.presence == nil {
+			return c.respond(s, i, "❌ The status of the bot can't be changed.")
+		}
+		return handler(ctx, s, i, options)
+	}
+}
+
+// listPresence shows the templates of the rotation
+func (c *AdminCommand) listPresence(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, _ []*discordgo.ApplicationCommandInteractionDataOption) error {
+	templates := c.presence.Templates()
+	if len(templates) == 0 {
+		return c.respond(s, i, "The rotation is empty, the default status is shown.")
+	}
+
+	var list strings.Builder
+	for position, template := range templates {
+		list.WriteString(fmt.Sprintf("%d. `%s`\n", position+1, template))
+	}
+	return c.respond(s, i, list.String())
+}
+
+// addPresence adds a template to the rotation
+func (c *AdminCommand) addPresence(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	var template string
+	for _, option := range options {
+		if option.Name == "template" {
+			template = strings.TrimSpace(option.StringValue())
+		}
+	}
+
+	if err := c.presence.AddTemplate(ctx, template); err != nil {
+		return c.respond(s, i, fmt.Sprintf("❌ %v", err))
+	}
+
+	c.logger.WithContext(ctx).WithFields(log.Fields{
+		"user":     interaction.User(i.Interaction).Username,
+		"template": template,
+	}).Info("Presence template added")
+
+	return c.respond(s, i, fmt.Sprintf("✅ `%s` was added to the rotation.", template))
+}
+
+// removePresence removes a template from the rotation
+func (c *AdminCommand) removePresence(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	var position int
+	for _, option := range options {
+		if option.Name == "position" {
+			position = int(option.IntValue())
+		}
+	}
+
+	template, err := c.presence.RemoveTemplate(ctx, position)
+	if err != nil {
+		return c.respond(s, i, fmt.Sprintf("❌ %v", err))
+	}
+
+	c.logger.WithContext(ctx).WithFields(log.Fields{
+		"user":     interaction.User(i.Interaction).Username,
+		"template": template,
+	}).Info("Presence template removed")
+
+	return c.respond(s, i, fmt.Sprintf("✅ `%s` was removed from the rotation.", template))
+}
+
+// resetPresence restores the templates of the configuration
+func (c *AdminCommand) resetPresence(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, _ []*discordgo.ApplicationCommandInteractionDataOption) error {
+	c.presence.Reset(ctx)
+
+	c.logger.WithContext(ctx).WithField("user", interaction.User(i.Interaction).Username).Info("Presence templates reset")
+
+	return c.respond(s, i, "✅ The statuses of the configuration are shown again.")
+}
+
+// featureOptions returns the command and the guild given to a feature subcommand
+func (c *AdminCommand) featureOptions(options []*discordgo.ApplicationCommandInteractionDataOption) (Command, string, error) {
+	var name, guildID string
+	for _, option := range options {
+		switch option.Name {
+		case "command":
+			name = strings.TrimPrefix(strings.TrimSpace(option.StringValue()), "/")
+		case "guild":
+			guildID = strings.TrimSpace(option.StringValue())
+		}
+	}
+
+	if guildID != database.GlobalGuildID && !interaction.IsSnowflake(guildID) {
+		return nil, "", fmt.Errorf("`%s` isn't a server ID", guildID)
+	}
+
+	cmd, ok := c.registry.Get(name)
+	if !ok {
+		return nil, "", fmt.Errorf("unknown command `%s`", name)
+	}
+	// the owner would lock themselves out
+	if cmd == Command(c) {
+		return nil, "", fmt.Errorf("`/%s` can't be toggled", c.Name())
+	}
+
+	return cmd, guildID, nil
+}
+
+// syncFeatures answers the owner then registers the commands again, moving a command between the global and the guild
+// scopes can take a while
+func (c *AdminCommand) syncFeatures(s *discordgo.Session, i *discordgo.InteractionCreate, guildID string, cmd Command, message string) error {
+	if err := c.respond(s, i, message+" Synchronizing the commands…"); err != nil {
+		return err
+	}
+
+	started := c.jobs.Go("sync commands", func(context.Context) {
+		if err := c.syncCommands(s, guildID, strings.ToLower(cmd.Name())); err != nil {
+			c.logger.WithError(err).Error("Failed to synchronize slash commands after a toggle changed")
+		}
+	})
+	if !started {
+		c.logger.Warn("Shutting down, the slash commands will be synchronized on the next start")
+	}
+
+	return nil
+}
+
+// Autocomplete suggests the names of the commands
+func (c *AdminCommand) Autocomplete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	return commandChoices(c.registry, i), nil
+}
+
+// guildLabel describes the guild of a toggle
+func guildLabel(guildID string) string {
+	if guildID == database.GlobalGuildID {
+		return "every server"
+	}
+	return fmt.Sprintf("server `%s`", guildID)
+}
+
+func (c *AdminCommand) respond(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func (c *AdminCommand) editResponse(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: stringPtr(message),
+	})
+	return err
+}
+
+func (c *AdminCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	// hide the command from regular members, the owner check is done at execution
+	permissions := int64(discordgo.PermissionAdministrator)
+	dmPermission := false
+
+	return &discordgo.ApplicationCommand{
+		Name:                     c.Name(),
+		Description:              c.Description(),
+		DefaultMemberPermissions: &permissions,
+		DMPermission:             &dmPermission,
+		Options:                  SubcommandOptions(c),
+	}
+}
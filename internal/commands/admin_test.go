@@ -0,0 +1,184 @@
+package commands_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/commands"
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/database/memory"
+	"github.com/borisjacquot/juno/internal/lifecycle"
+	"github.com/bwmarrin/discordgo"
+)
+
+const otherGuildID = "100000000000000002"
+
+// newAdminCommand returns the admin command owned by the test user, the toggles it synchronizes are sent to synced
+func newAdminCommand(t *testing.T, store database.Store) (*commands.AdminCommand, chan string) {
+	t.Helper()
+
+	logger := newLogger()
+	registry := commands.NewRegistry(logger)
+	if err := registry.Register(commands.NewRegisterCommand(store, logger)); err != nil {
+		t.Fatalf("failed to register command: %v", err)
+	}
+
+	synced := make(chan string, 10)
+	syncCommands := func(_ *discordgo.Session, guildID, command string) error {
+		synced <- guildID + "/" + command
+		return nil
+	}
+
+	jobs := lifecycle.NewGroup(logger)
+	t.Cleanup(func() {
+		jobs.Shutdown(time.Second)
+	})
+
+	cmd := commands.NewAdminCommand(registry, nil, store, syncCommands, jobs, nil, testUserID, logger)
+	if err := registry.Register(cmd); err != nil {
+		t.Fatalf("failed to register command: %v", err)
+	}
+	return cmd, synced
+}
+
+func TestAdminFeatures(t *testing.T) {
+	// the steps run in order against the same store
+	steps := []struct {
+		name        string
+		userID      string
+		subcommand  *discordgo.ApplicationCommandInteractionDataOption
+		wantMessage string
+		wantSync    string
+		wantToggles []string
+	}{
+		{
+			name:        "disable in a guild",
+			subcommand:  groupSubcommand("feature", "disable", stringOption("command", "register"), stringOption("guild", otherGuildID)),
+			wantMessage: "is now disabled in server `" + otherGuildID + "`",
+			wantSync:    otherGuildID + "/register",
+			wantToggles: []string{otherGuildID + "/register=false"},
+		},
+		{
+			name:        "enable everywhere",
+			subcommand:  groupSubcommand("feature", "enable", stringOption("command", "register")),
+			wantMessage: "is now enabled in every server",
+			wantSync:    "/register",
+			wantToggles: []string{"/register=true", otherGuildID + "/register=false"},
+		},
+		{
+			name:        "reset in a guild",
+			subcommand:  groupSubcommand("feature", "reset", stringOption("command", "register"), stringOption("guild", otherGuildID)),
+			wantMessage: "uses the default again",
+			wantSync:    otherGuildID + "/register",
+			wantToggles: []string{"/register=true"},
+		},
+		{
+			name:        "reset without toggle",
+			subcommand:  groupSubcommand("feature", "reset", stringOption("command", "register"), stringOption("guild", otherGuildID)),
+			wantMessage: "isn't toggled",
+			wantToggles: []string{"/register=true"},
+		},
+		{
+			name:        "invalid guild ID",
+			subcommand:  groupSubcommand("feature", "disable", stringOption("command", "register"), stringOption("guild", "my server")),
+			wantMessage: "isn't a server ID",
+			wantToggles: []string{"/register=true"},
+		},
+		{
+			name:        "admin command",
+			subcommand:  groupSubcommand("feature", "disable", stringOption("command", "admin")),
+			wantMessage: "can't be toggled",
+			wantToggles: []string{"/register=true"},
+		},
+		{
+			name:        "not the owner",
+			userID:      "200000000000000002",
+			subcommand:  groupSubcommand("feature", "disable", stringOption("command", "register")),
+			wantMessage: "reserved to the bot owner",
+			wantToggles: []string{"/register=true"},
+		},
+		{
+			name:        "list",
+			subcommand:  groupSubcommand("feature", "list"),
+			wantMessage: "✅ `register` in every server",
+			wantToggles: []string{"/register=true"},
+		},
+	}
+
+	ctx := context.Background()
+	store := memory.New()
+	cmd, synced := newAdminCommand(t, store)
+	s, discord := newSession(t)
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			i := slashInteraction("admin", step.subcommand)
+			if step.userID != "" {
+				i.Member.User.ID = step.userID
+			}
+
+			if err := cmd.ExecuteSlash(ctx, s, i); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(discord.last(), step.wantMessage) {
+				t.Errorf("message = %q, want %q", discord.last(), step.wantMessage)
+			}
+
+			if step.wantSync != "" {
+				select {
+				case got := <-synced:
+					if got != step.wantSync {
+						t.Errorf("synchronized %q, want %q", got, step.wantSync)
+					}
+				case <-time.After(time.Second):
+					t.Errorf("commands weren't synchronized, want %q", step.wantSync)
+				}
+			}
+
+			toggles, err := store.GetCommandToggles(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := []string{}
+			for _, toggle := range toggles {
+				got = append(got, fmt.Sprintf("%s/%s=%t", toggle.GuildID, toggle.Command, toggle.Enabled))
+			}
+			if strings.Join(got, " ") != strings.Join(step.wantToggles, " ") {
+				t.Errorf("toggles = %v, want %v", got, step.wantToggles)
+			}
+		})
+	}
+
+	select {
+	case got := <-synced:
+		t.Errorf("unexpected synchronization of %q", got)
+	default:
+	}
+}
+
+func TestAdminFeatureListLength(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	for n := range 100 {
+		if err := store.SetCommandToggle(ctx, fmt.Sprintf("1000000000000%05d", n), "register", false); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	cmd, _ := newAdminCommand(t, store)
+	s, discord := newSession(t)
+
+	if err := cmd.ExecuteSlash(ctx, s, slashInteraction("admin", groupSubcommand("feature", "list"))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	list := discord.last()
+	if length := len([]rune(list)); length > 2000 {
+		t.Errorf("list has %d characters, want at most 2000", length)
+	}
+	if !strings.Contains(list, "more") {
+		t.Errorf("list = %q, want the number of toggles left out", list)
+	}
+}
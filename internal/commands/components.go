@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// customIDSeparator separates the prefix, the issue time and the state values of a custom ID
+	customIDSeparator = ":"
+
+	// maxCustomIDLength is the maximum length of a custom ID accepted by Discord
+	maxCustomIDLength = 100
+
+	// ComponentTTL is how long the components sent by the bot stay usable
+	ComponentTTL = 30 * time.Minute
+)
+
+// ComponentHandler is implemented by commands handling the message components (buttons, select menus) they send
+// Components are routed to the command by the prefix of their custom ID, the rest of the custom ID carries their state
+// so they keep working after a restart of the bot
+type ComponentHandler interface {
+	// ComponentPrefix returns the prefix of the custom IDs handled by the command (must not contain ':')
+	ComponentPrefix() string
+
+	// HandleComponent handles a click on a button or a choice in a select menu sent by the command
+	HandleComponent(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, state []string) error
+}
+
+// EncodeCustomID builds the custom ID of a component from the prefix of its handler and its state
+// The issue time is encoded too so expired components can be detected, the result must fit in 100 characters
+func EncodeCustomID(prefix string, state ...string) (string, error) {
+	parts := []string{prefix, strconv.FormatInt(time.Now().Unix(), 36)}
+	for _, value := range state {
+		parts = append(parts, url.QueryEscape(value))
+	}
+
+	customID := strings.Join(parts, customIDSeparator)
+	if len(customID) > maxCustomIDLength {
+		return "", fmt.Errorf("custom ID of '%s' component is too long (%d characters)", prefix, len(customID))
+	}
+
+	return customID, nil
+}
+
+// DecodeCustomID extracts the prefix, the issue time and the state of a custom ID built by EncodeCustomID
+func DecodeCustomID(customID string) (prefix string, issuedAt time.Time, state []string, err error) {
+	parts := strings.Split(customID, customIDSeparator)
+	if len(parts) < 2 {
+		return "", time.Time{}, nil, fmt.Errorf("malformed custom ID '%s'", customID)
+	}
+
+	timestamp, err := strconv.ParseInt(parts[1], 36, 64)
+	if err != nil {
+		return "", time.Time{}, nil, fmt.Errorf("malformed custom ID '%s': %w", customID, err)
+	}
+
+	state = make([]string, 0, len(parts)-2)
+	for _, part := range parts[2:] {
+		value, err := url.QueryUnescape(part)
+		if err != nil {
+			return "", time.Time{}, nil, fmt.Errorf("malformed custom ID '%s': %w", customID, err)
+		}
+		state = append(state, value)
+	}
+
+	return parts[0], time.Unix(timestamp, 0), state, nil
+}
+
+// respondExpired tells the user a component can't be used anymore
+func respondExpired(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "⌛ This message has expired, run the command again.",
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// ModalHandler is implemented by commands opening modals, their submissions are routed back to the command
+// by the prefix of the modal custom ID, built with EncodeCustomID like components
+type ModalHandler interface {
+	// ModalPrefix returns the prefix of the custom IDs of the modals opened by the command (must not contain ':')
+	ModalPrefix() string
+
+	// HandleModal handles the submission of a modal opened by the command
+	HandleModal(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, state []string) error
+}
+
+// ModalValues returns the values of the text inputs of a submitted modal, by custom ID
+func ModalValues(data discordgo.ModalSubmitInteractionData) map[string]string {
+	values := make(map[string]string)
+	for _, component := range data.Components {
+		row, ok := component.(*discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, rowComponent := range row.Components {
+			if input, ok := rowComponent.(*discordgo.TextInput); ok {
+				values[input.CustomID] = strings.TrimSpace(input.Value)
+			}
+		}
+	}
+	return values
+}
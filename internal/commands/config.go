@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/interaction"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxWelcomeMessageLength is the maximum length of the welcome message of a guild
+const maxWelcomeMessageLength = 1000
+
+// ConfigCommand lets guild admins configure the bot for their guild
+type ConfigCommand struct {
+	settings database.SettingsStore
+	logger   *log.Logger
+}
+
+func NewConfigCommand(settings database.SettingsStore, logger *log.Logger) *ConfigCommand {
+	return &ConfigCommand{
+		settings: settings,
+		logger:   logger,
+	}
+}
+
+func (c *ConfigCommand) Name() string {
+	return "config"
+}
+
+func (c *ConfigCommand) Description() string {
+	return "Configure the bot for this server"
+}
+
+func (c *ConfigCommand) Category() string {
+	return "Admin"
+}
+
+func (c *ConfigCommand) GuildOnly() bool {
+	return true
+}
+
+func (c *ConfigCommand) RequiredPermissions() int64 {
+	return discordgo.PermissionManageGuild
+}
+
+// ExecuteSlash updates the settings given as options, without options it shows the current configuration
+func (c *ConfigCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	settings, err := c.settings.GetGuildSettings(ctx, i.GuildID)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to get guild settings from database")
+		return c.respondError(s, i, "❌ Failed to retrieve the server configuration.")
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		return c.respondSettings(s, i, "⚙️ Server Configuration", settings)
+	}
+
+	for _, option := range options {
+		switch option.Name {
+		case "welcome_message":
+			settings.WelcomeMessage = option.StringValue()
+		case "reset_welcome_message":
+			if option.BoolValue() {
+				settings.WelcomeMessage = ""
+			}
+		}
+	}
+
+	if err := c.settings.SaveGuildSettings(ctx, settings); err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to save guild settings in database")
+		return c.respondError(s, i, "❌ Failed to save the server configuration. Please try again later.")
+	}
+
+	c.logger.WithContext(ctx).WithFields(log.Fields{
+		"user":     interaction.User(i.Interaction).Username,
+		"guild_id": i.GuildID,
+	}).Info("Guild configuration updated")
+
+	return c.respondSettings(s, i, "✅ Configuration Saved", settings)
+}
+
+// respondSettings shows the configuration of the guild in an embed
+func (c *ConfigCommand) respondSettings(s *discordgo.Session, i *discordgo.InteractionCreate, title string, settings *database.GuildSettings) error {
+	welcomeMessage := settings.WelcomeMessage
+	if welcomeMessage == "" {
+		welcomeMessage = "*Default*"
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{
+				{
+					Title: title,
+					Color: 0xD183C9,
+					Fields: []*discordgo.MessageEmbedField{
+						{
+							Name:   "👋 Welcome Message",
+							Value:  welcomeMessage,
+							Inline: false,
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func (c *ConfigCommand) respondError(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func (c *ConfigCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	permissions := int64(discordgo.PermissionManageGuild)
+	dmPermission := false
+
+	return &discordgo.ApplicationCommand{
+		Name:                     c.Name(),
+		Description:              c.Description(),
+		DefaultMemberPermissions: &permissions,
+		DMPermission:             &dmPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "welcome_message",
+				Description: "Message shown when the bot joins the server",
+				Required:    false,
+				MaxLength:   maxWelcomeMessageLength,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "reset_welcome_message",
+				Description: "Go back to the default welcome message",
+				Required:    false,
+			},
+		},
+	}
+}
@@ -1,39 +1,103 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"runtime/debug"
+	"time"
 
 	owcommands "github.com/borisjacquot/juno/internal/commands/overwatch"
+	"github.com/borisjacquot/juno/internal/config"
+	"github.com/borisjacquot/juno/internal/cooldown"
 	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/lifecycle"
 	"github.com/borisjacquot/juno/internal/overwatch"
 	"github.com/bwmarrin/discordgo"
 	log "github.com/sirupsen/logrus"
 )
 
+// maxAutocompleteChoices is the maximum number of suggestions Discord accepts
+const maxAutocompleteChoices = 25
+
 type Handler struct {
-	registry *Registry
-	logger   *log.Logger
+	registry    *Registry
+	middlewares []Middleware
+	reporter    *ErrorReporter
+	cooldowns   *cooldown.Tracker
+	features    database.FeatureStore
+	devGuildIDs []string
+	guilds      func() []string
+	logger      *log.Logger
 }
 
 // NewHandler creates a new command handler
-func NewHandler(owClient *overwatch.Client, db *database.Database, logger *log.Logger) *Handler {
+// Panics are reported with an incident ID and posted to the error channel of the configuration unless it's empty
+// Commands are registered in the development guilds instead of globally when they are configured
+// Optional commands and cooldowns are only enabled by their feature flags
+// Background jobs started by commands are tracked by jobs, executions are recorded with recorder unless it's nil
+// The status of the bot is changed with presence, /admin presence answers with an error when it's nil
+func NewHandler(cfg *config.Config, owClient *overwatch.Client, store database.Store, backups database.BackupStore, jobs *lifecycle.Group, presence PresenceManager, recorder MetricsRecorder, logger *log.Logger) *Handler {
 	registry := NewRegistry(logger)
 
+	handler := &Handler{
+		registry:    registry,
+		reporter:    NewErrorReporter(cfg.Discord.ErrorChannelID, logger),
+		cooldowns:   cooldown.NewTracker(),
+		features:    store,
+		devGuildIDs: cfg.Discord.DevGuildIDs,
+		logger:      logger,
+	}
+
 	// Register general commands
 	pingCmd := NewPingCommand(logger)
 	if err := registry.Register(pingCmd); err != nil {
 		logger.WithError(err).Error("Failed to register ping command")
 	}
-	registerCmd := NewRegisterCommand(db, logger)
+	registerCmd := NewRegisterCommand(store, logger)
 	if err := registry.Register(registerCmd); err != nil {
 		logger.WithError(err).Error("Failed to register register command")
 	}
 
 	// Register Overwatch commands
-	profileCmd := owcommands.NewProfileCommand(owClient, db, logger)
+	profileCmd := owcommands.NewProfileCommand(owClient, store, logger)
 	if err := registry.Register(profileCmd); err != nil {
 		logger.WithError(err).Error("Failed to register profile command")
 	}
+	if cfg.Features.ContextMenus {
+		profileUserCmd := owcommands.NewProfileUserCommand(profileCmd)
+		if err := registry.Register(profileUserCmd); err != nil {
+			logger.WithError(err).Error("Failed to register profile user command")
+		}
+	}
+	heroCmd := owcommands.NewHeroCommand(owClient, logger)
+	if err := registry.Register(heroCmd); err != nil {
+		logger.WithError(err).Error("Failed to register hero command")
+	}
+	mapCmd := owcommands.NewMapCommand(owClient, logger)
+	if err := registry.Register(mapCmd); err != nil {
+		logger.WithError(err).Error("Failed to register map command")
+	}
+
+	// Register admin commands
+	configCmd := NewConfigCommand(store, logger)
+	if err := registry.Register(configCmd); err != nil {
+		logger.WithError(err).Error("Failed to register config command")
+	}
+	if cfg.Features.RegistrationTransfer {
+		registrationsCmd := NewRegistrationsCommand(store, cfg.Discord.DownloadTimeout, logger)
+		if err := registry.Register(registrationsCmd); err != nil {
+			logger.WithError(err).Error("Failed to register registrations command")
+		}
+	}
+	permissionsCmd := NewPermissionsCommand(registry, store, logger)
+	if err := registry.Register(permissionsCmd); err != nil {
+		logger.WithError(err).Error("Failed to register permissions command")
+	}
+	adminCmd := NewAdminCommand(registry, backups, store, handler.SyncToggledCommand, jobs, presence, cfg.Discord.OwnerID, logger)
+	if err := registry.Register(adminCmd); err != nil {
+		logger.WithError(err).Error("Failed to register admin command")
+	}
 
 	// Register help command
 	helpCmd := NewHelpCommand(registry, logger)
@@ -49,61 +113,219 @@ func NewHandler(owClient *overwatch.Client, db *database.Database, logger *log.L
 		}).Debug("Command available")
 	}
 
-	return &Handler{
-		registry: registry,
-		logger:   logger,
+	// the first middleware is the outermost one, metrics wrap the recovery so panics are counted as failures
+	if recorder != nil {
+		handler.Use(MetricsMiddleware(recorder))
 	}
+	handler.Use(
+		RecoveryMiddleware(handler.reporter),
+		TimingMiddleware(logger),
+		LoggingMiddleware(logger),
+		GuildOnlyMiddleware(),
+		PermissionMiddleware(),
+		RestrictionMiddleware(store, logger),
+	)
+	if cfg.Features.Cooldowns {
+		handler.Use(CooldownMiddleware(handler.cooldowns))
+	}
+
+	return handler
 }
 
-// RegisterSlashCommands registers all commands as slash commands with Discord
-func (h *Handler) RegisterSlashCommands(s *discordgo.Session) error {
-	commands := h.registry.All()
+// HandleSlashCommand handles incoming slash command interactions
+func (h *Handler) HandleSlashCommand(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	cmdName := i.ApplicationCommandData().Name
 
-	for _, cmd := range commands {
-		slashCmd := cmd.ToApplicationCommand()
+	cmd, ok := h.registry.Get(cmdName)
+	if !ok {
+		h.logger.WithContext(ctx).WithField("command", cmdName).Debug("Slash command not found")
+		respondWithError(s, i, "Unknown command.")
+		return
+	}
 
-		h.logger.WithField("command", slashCmd.Name).Debug("Registering slash command")
+	execution := &Execution{
+		Context:     ctx,
+		Command:     cmd,
+		Path:        cmd.Name(),
+		Session:     s,
+		Interaction: i,
+		Options:     i.ApplicationCommandData().Options,
+	}
+	execute := func(e *Execution) error {
+		return e.Command.ExecuteSlash(e.Context, e.Session, e.Interaction)
+	}
 
-		_, err := s.ApplicationCommandCreate(s.State.User.ID, "", slashCmd)
+	// group commands are routed to the invoked subcommand
+	if group, ok := cmd.(GroupCommand); ok {
+		sub, path, options, err := ResolveSubcommand(group, execution.Options)
 		if err != nil {
-			h.logger.WithError(err).WithField("command", slashCmd.Name).Error("Failed to register slash command")
-			return err
+			h.logger.WithContext(ctx).WithError(err).WithField("command", cmdName).Debug("Subcommand not found")
+			respondWithError(s, i, "Unknown command.")
+			return
+		}
+
+		execution.Path = path
+		execution.Options = options
+		execute = func(e *Execution) error {
+			return sub.Handler(e.Context, e.Session, e.Interaction, e.Options)
 		}
 	}
 
-	h.logger.WithField("count", len(commands)).Info("Registered slash commands")
-	return nil
+	h.execute(execution, execute, "Error executing command")
 }
 
-// HandleSlashCommand handles incoming slash command interactions
-func (h *Handler) HandleSlashCommand(s *discordgo.Session, i *discordgo.InteractionCreate) {
+// execute runs an execution through the middleware chain and tells the user when it fails
+func (h *Handler) execute(execution *Execution, execute ExecuteFunc, failure string) {
+	if err := chain(execute, h.middlewares)(execution); err != nil {
+		// panics were already logged with their stack trace
+		var incident *IncidentError
+		if errors.As(err, &incident) {
+			respondWithError(execution.Session, execution.Interaction, incident.Error())
+			return
+		}
+
+		h.logger.WithContext(execution.Context).WithError(err).WithField("command", execution.Path).Error(failure)
+		respondWithError(execution.Session, execution.Interaction, fmt.Sprintf("%s: %v", failure, err))
+	}
+}
+
+// Recover recovers from a panic while routing an interaction, reports it and tells the user
+// It must be called directly with defer, recover only stops the panic when called by the deferred function itself
+func (h *Handler) Recover(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	incident := h.reporter.Report(ctx, s, i, fmt.Sprintf("interaction type %s", i.Type), value, debug.Stack())
+	respondWithError(s, i, incident.Error())
+}
+
+// Use appends middlewares to the chain wrapping the execution of every command
+func (h *Handler) Use(middlewares ...Middleware) {
+	h.middlewares = append(h.middlewares, middlewares...)
+}
+
+// HandleAutocomplete answers autocomplete interactions with the suggestions of the command
+func (h *Handler) HandleAutocomplete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
 	cmdName := i.ApplicationCommandData().Name
 
+	var choices []*discordgo.ApplicationCommandOptionChoice
+
 	cmd, ok := h.registry.Get(cmdName)
 	if !ok {
-		h.logger.WithField("command", cmdName).Debug("Slash command not found")
-		respondWithError(s, i, "Unknown command.")
+		h.logger.WithContext(ctx).WithField("command", cmdName).Debug("Autocomplete command not found")
+	} else if completer, ok := cmd.(Autocompleter); ok {
+		var err error
+		choices, err = completer.Autocomplete(ctx, s, i)
+		if err != nil {
+			h.logger.WithContext(ctx).WithError(err).WithField("command", cmdName).Warn("Failed to build autocomplete suggestions")
+			choices = nil
+		}
+	}
+
+	// Discord rejects more than 25 choices
+	if len(choices) > maxAutocompleteChoices {
+		choices = choices[:maxAutocompleteChoices]
+	}
+	if choices == nil {
+		choices = []*discordgo.ApplicationCommandOptionChoice{}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{
+			Choices: choices,
+		},
+	})
+	if err != nil {
+		h.logger.WithContext(ctx).WithError(err).WithField("command", cmdName).Debug("Failed to send autocomplete suggestions")
+	}
+}
+
+// HandleComponent routes button clicks and select menu choices to the command that sent them
+func (h *Handler) HandleComponent(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	customID := i.MessageComponentData().CustomID
+
+	prefix, issuedAt, state, err := DecodeCustomID(customID)
+	if err != nil {
+		// components sent by an older version of the bot
+		h.logger.WithContext(ctx).WithError(err).Debug("Received component with unknown custom ID")
+		respondExpired(s, i)
 		return
 	}
 
-	h.logger.WithFields(log.Fields{
-		"user":    i.Member.User.Username,
-		"command": cmdName,
-		"options": i.ApplicationCommandData().Options,
-	}).Info("Executing slash command")
+	cmd, ok := h.registry.Component(prefix)
+	if !ok || time.Since(issuedAt) > ComponentTTL {
+		h.logger.WithContext(ctx).WithFields(log.Fields{
+			"custom_id": customID,
+			"issued_at": issuedAt,
+		}).Debug("Received stale component interaction")
+		respondExpired(s, i)
+		return
+	}
 
-	if err := cmd.ExecuteSlash(s, i); err != nil {
-		h.logger.WithError(err).WithField("command", cmdName).Error("Error executing slash command")
-		respondWithError(s, i, fmt.Sprintf("Error executing command: %v", err))
+	// components go through the checks of the command that sent them, under their own name
+	execution := &Execution{
+		Context:     ctx,
+		Command:     cmd,
+		Path:        cmd.Name() + " component",
+		Session:     s,
+		Interaction: i,
 	}
+	h.execute(execution, func(e *Execution) error {
+		return e.Command.(ComponentHandler).HandleComponent(e.Context, e.Session, e.Interaction, state)
+	}, "Error handling interaction")
 }
 
+// HandleModalSubmit routes modal submissions to the command that opened the modal
+func (h *Handler) HandleModalSubmit(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	customID := i.ModalSubmitData().CustomID
+
+	prefix, issuedAt, state, err := DecodeCustomID(customID)
+	if err != nil {
+		h.logger.WithContext(ctx).WithError(err).Debug("Received modal with unknown custom ID")
+		respondExpired(s, i)
+		return
+	}
+
+	cmd, ok := h.registry.Modal(prefix)
+	if !ok || time.Since(issuedAt) > ComponentTTL {
+		h.logger.WithContext(ctx).WithFields(log.Fields{
+			"custom_id": customID,
+			"issued_at": issuedAt,
+		}).Debug("Received stale modal submission")
+		respondExpired(s, i)
+		return
+	}
+
+	// like components, submissions go through the checks of the command that opened the modal
+	execution := &Execution{
+		Context:     ctx,
+		Command:     cmd,
+		Path:        cmd.Name() + " modal",
+		Session:     s,
+		Interaction: i,
+	}
+	h.execute(execution, func(e *Execution) error {
+		return e.Command.(ModalHandler).HandleModal(e.Context, e.Session, e.Interaction, state)
+	}, "Error handling form")
+}
+
+// respondWithError answers the interaction with an error, editing the response if it was already acknowledged
 func respondWithError(s *discordgo.Session, i *discordgo.InteractionCreate, message string) {
-	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
 			Content: "❌ " + message,
 			Flags:   discordgo.MessageFlagsEphemeral,
 		},
 	})
+	if err != nil {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content:    stringPtr("❌ " + message),
+			Embeds:     &[]*discordgo.MessageEmbed{},
+			Components: &[]discordgo.MessageComponent{},
+		})
+	}
 }
@@ -1,10 +1,14 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/borisjacquot/juno/internal/interaction"
 	"github.com/bwmarrin/discordgo"
 	log "github.com/sirupsen/logrus"
 )
@@ -33,7 +37,7 @@ func (c *HelpCommand) Category() string {
 	return "General"
 }
 
-func (c *HelpCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+func (c *HelpCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
 	options := i.ApplicationCommandData().Options
 
 	// instant response to acknowledge the command
@@ -55,7 +59,85 @@ func (c *HelpCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.Interactio
 }
 
 // showGeneralHelp sends a message with a list of all commands and their descriptions
-func (c *HelpCommand) showGeneralHelp(s *discordgo.Session, channelID string, interaction *discordgo.Interaction) error {
+func (c *HelpCommand) showGeneralHelp(s *discordgo.Session, channelID string, ia *discordgo.Interaction) error {
+	embed := c.helpPage(0)
+
+	if ia != nil {
+		edit := &discordgo.WebhookEdit{
+			Embeds: &[]*discordgo.MessageEmbed{embed},
+		}
+
+		// let the user browse the categories
+		components, err := c.helpComponents(interaction.User(ia).ID, 0)
+		if err != nil {
+			c.logger.WithError(err).Warn("Failed to build help components")
+		} else {
+			edit.Components = &components
+		}
+
+		_, err = s.InteractionResponseEdit(ia, edit)
+		return err
+	}
+
+	_, err := s.ChannelMessageSendEmbed(channelID, embed)
+	return err
+}
+
+// ComponentPrefix returns the prefix of the custom IDs of the help menu components
+func (c *HelpCommand) ComponentPrefix() string {
+	return "help"
+}
+
+// HandleComponent switches the page of a help message when its select menu or buttons are used
+// state holds the ID of the user who opened the help, the action ("select" or "page") and the page for buttons
+func (c *HelpCommand) HandleComponent(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, state []string) error {
+	if len(state) < 2 {
+		return respondExpired(s, i)
+	}
+
+	if state[0] != interaction.User(i.Interaction).ID {
+		return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: "❌ Only the person who used `/help` can browse this menu. Use `/help` to open your own.",
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+
+	var rawPage string
+	switch state[1] {
+	case "select":
+		if values := i.MessageComponentData().Values; len(values) > 0 {
+			rawPage = values[0]
+		}
+	case "page":
+		if len(state) > 2 {
+			rawPage = state[2]
+		}
+	}
+
+	page, err := strconv.Atoi(rawPage)
+	if err != nil || page < 0 || page >= len(c.categoryNames())+1 {
+		return respondExpired(s, i)
+	}
+
+	components, err := c.helpComponents(state[0], page)
+	if err != nil {
+		return err
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:     []*discordgo.MessageEmbed{c.helpPage(page)},
+			Components: components,
+		},
+	})
+}
+
+// categoryNames returns the sorted names of the command categories, page n of the help shows category n-1
+func (c *HelpCommand) categoryNames() []string {
 	categories := c.registry.GetByCategory()
 
 	categoryNames := make([]string, 0, len(categories))
@@ -64,9 +146,17 @@ func (c *HelpCommand) showGeneralHelp(s *discordgo.Session, channelID string, in
 	}
 	sort.Strings(categoryNames)
 
+	return categoryNames
+}
+
+// helpPage builds the embed of a help page, page 0 is the overview of every category
+func (c *HelpCommand) helpPage(page int) *discordgo.MessageEmbed {
+	categories := c.registry.GetByCategory()
+	categoryNames := c.categoryNames()
+
 	embed := &discordgo.MessageEmbed{
 		Title:       "📖 Help - Juno Bot",
-		Description: "Here's a list of all available commands. Use `/help command:<name>` for detailed information about a specific command.",
+		Description: "Here's a list of all available commands. Pick a category below to see their subcommands, or use `/help command:<name>` for detailed information about a specific command.",
 		Color:       0xD183C9,
 		Fields:      []*discordgo.MessageEmbedField{},
 		Footer: &discordgo.MessageEmbedFooter{
@@ -75,45 +165,126 @@ func (c *HelpCommand) showGeneralHelp(s *discordgo.Session, channelID string, in
 		},
 	}
 
-	for _, category := range categoryNames {
-		commands := categories[category]
+	// the overview only lists the commands, the page of their category also lists their subcommands
+	shown := categoryNames
+	if page > 0 {
+		shown = categoryNames[page-1 : page]
+		embed.Title = fmt.Sprintf("📖 Help - %s", shown[0])
+		embed.Footer.Text = fmt.Sprintf("Page %d/%d | Juno Bot", page, len(categoryNames))
+	}
 
-		var commandList strings.Builder
-		for _, cmd := range commands {
-			commandList.WriteString(fmt.Sprintf("**/%s** - %s\n", cmd.Name(), cmd.Description()))
+	for _, category := range shown {
+		var lines []string
+		for _, cmd := range categories[category] {
+			lines = append(lines, fmt.Sprintf("**%s** - %s", commandLabel(cmd), cmd.Description()))
+			if group, ok := cmd.(GroupCommand); ok && page > 0 {
+				lines = append(lines, subcommandTree(group)...)
+			}
 		}
 
-		fieldValue := commandList.String()
-		if fieldValue == "" {
-			fieldValue = "No commands in this category"
+		if len(lines) == 0 {
+			lines = []string{"No commands in this category"}
 		}
 
-		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-			Name:   fmt.Sprintf("📌 %s", category),
-			Value:  fieldValue,
-			Inline: false,
-		})
+		embed.Fields = append(embed.Fields, splitField(fmt.Sprintf("📌 %s", category), lines)...)
 	}
 
-	if interaction != nil {
-		_, err := s.InteractionResponseEdit(interaction, &discordgo.WebhookEdit{
-			Embeds: &[]*discordgo.MessageEmbed{embed},
+	return embed
+}
+
+// helpComponents builds the category select menu and the pagination buttons of a help page
+func (c *HelpCommand) helpComponents(userID string, page int) ([]discordgo.MessageComponent, error) {
+	categoryNames := c.categoryNames()
+
+	selectID, err := EncodeCustomID(c.ComponentPrefix(), userID, "select")
+	if err != nil {
+		return nil, err
+	}
+	previousID, err := EncodeCustomID(c.ComponentPrefix(), userID, "page", strconv.Itoa(page-1))
+	if err != nil {
+		return nil, err
+	}
+	nextID, err := EncodeCustomID(c.ComponentPrefix(), userID, "page", strconv.Itoa(page+1))
+	if err != nil {
+		return nil, err
+	}
+
+	options := []discordgo.SelectMenuOption{
+		{
+			Label:   "Overview",
+			Value:   "0",
+			Emoji:   &discordgo.ComponentEmoji{Name: "📖"},
+			Default: page == 0,
+		},
+	}
+	for n, category := range categoryNames {
+		options = append(options, discordgo.SelectMenuOption{
+			Label:   category,
+			Value:   strconv.Itoa(n + 1),
+			Emoji:   &discordgo.ComponentEmoji{Name: "📌"},
+			Default: page == n+1,
 		})
-		return err
 	}
 
-	_, err := s.ChannelMessageSendEmbed(channelID, embed)
-	return err
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					CustomID:    selectID,
+					Placeholder: "Choose a category",
+					Options:     options,
+				},
+			},
+		},
+		discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Previous",
+					Style:    discordgo.SecondaryButton,
+					Emoji:    &discordgo.ComponentEmoji{Name: "◀️"},
+					CustomID: previousID,
+					Disabled: page == 0,
+				},
+				discordgo.Button{
+					Label:    "Next",
+					Style:    discordgo.SecondaryButton,
+					Emoji:    &discordgo.ComponentEmoji{Name: "▶️"},
+					CustomID: nextID,
+					Disabled: page == len(categoryNames),
+				},
+			},
+		},
+	}, nil
 }
 
 // showDetailedHelp sends a message with detailed information about a specific command
-func (c *HelpCommand) showDetailedHelp(s *discordgo.Session, channelID, cmdName string, interaction *discordgo.Interaction) error {
-	cmd, ok := c.registry.Get(cmdName)
+func (c *HelpCommand) showDetailedHelp(s *discordgo.Session, channelID, cmdName string, ia *discordgo.Interaction) error {
+	// "registrations export" shows the help of a subcommand
+	parts := strings.Fields(strings.TrimPrefix(strings.TrimSpace(cmdName), "/"))
+	if len(parts) == 0 {
+		return c.showGeneralHelp(s, channelID, ia)
+	}
+
+	// context menu command names contain spaces
+	cmd, ok := c.registry.Get(strings.Join(parts, " "))
+	if ok {
+		parts = []string{cmd.Name()}
+	} else {
+		cmd, ok = c.registry.Get(parts[0])
+	}
+	if ok && len(parts) > 1 {
+		if group, isGroup := cmd.(GroupCommand); isGroup {
+			if sub, found := findSubcommand(group, parts[1:]); found {
+				return c.showSubcommandHelp(s, channelID, cmd, strings.Join(parts, " "), sub, ia)
+			}
+		}
+		ok = false
+	}
 	if !ok {
 		errMsg := fmt.Sprintf("❌ Command '%s' not found", cmdName)
 
-		if interaction != nil {
-			_, err := s.InteractionResponseEdit(interaction, &discordgo.WebhookEdit{
+		if ia != nil {
+			_, err := s.InteractionResponseEdit(ia, &discordgo.WebhookEdit{
 				Content: &errMsg,
 			})
 			return err
@@ -127,7 +298,7 @@ func (c *HelpCommand) showDetailedHelp(s *discordgo.Session, channelID, cmdName
 	appCmd := cmd.ToApplicationCommand()
 
 	embed := &discordgo.MessageEmbed{
-		Title:       fmt.Sprintf("📖 Help - /%s", cmd.Name()),
+		Title:       fmt.Sprintf("📖 Help - %s", strings.ReplaceAll(commandLabel(cmd), "*", "")),
 		Description: cmd.Description(),
 		Color:       0xD183C9,
 		Fields:      []*discordgo.MessageEmbedField{},
@@ -137,25 +308,40 @@ func (c *HelpCommand) showDetailedHelp(s *discordgo.Session, channelID, cmdName
 		},
 	}
 
-	if len(appCmd.Options) > 0 {
-		var optionsList strings.Builder
-		for _, opt := range appCmd.Options {
-			required := ""
-			if opt.Required {
-				required = " *(required)*"
-			}
-			optionsList.WriteString(fmt.Sprintf("• **%s**%s - %s\n", opt.Name, required, opt.Description))
-		}
+	if group, ok := cmd.(GroupCommand); ok {
+		embed.Fields = append(embed.Fields, splitField("🧩 Subcommands", subcommandTree(group))...)
+		embed.Description += fmt.Sprintf("\n\nUse `/help command:%s <subcommand>` for details about a subcommand.", cmd.Name())
+	} else if field := optionsField(appCmd.Options); field != nil {
+		embed.Fields = append(embed.Fields, field)
+	}
 
-		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-			Name:   "⚙️ Options",
-			Value:  optionsList.String(),
-			Inline: false,
-		})
+	return c.sendEmbed(s, channelID, embed, ia)
+}
+
+// showSubcommandHelp sends a message with detailed information about a subcommand
+func (c *HelpCommand) showSubcommandHelp(s *discordgo.Session, channelID string, cmd Command, path string, sub *Subcommand, ia *discordgo.Interaction) error {
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("📖 Help - /%s", path),
+		Description: sub.Description,
+		Color:       0xD183C9,
+		Fields:      []*discordgo.MessageEmbedField{},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text:    fmt.Sprintf("Category: %s", cmd.Category()),
+			IconURL: "https://raw.githubusercontent.com/borisjacquot/juno/main/assets/img/icon.png",
+		},
+	}
+
+	if field := optionsField(sub.Options); field != nil {
+		embed.Fields = append(embed.Fields, field)
 	}
 
-	if interaction != nil {
-		_, err := s.InteractionResponseEdit(interaction, &discordgo.WebhookEdit{
+	return c.sendEmbed(s, channelID, embed, ia)
+}
+
+// sendEmbed edits the ia response with the embed, or sends it to the channel
+func (c *HelpCommand) sendEmbed(s *discordgo.Session, channelID string, embed *discordgo.MessageEmbed, ia *discordgo.Interaction) error {
+	if ia != nil {
+		_, err := s.InteractionResponseEdit(ia, &discordgo.WebhookEdit{
 			Embeds: &[]*discordgo.MessageEmbed{embed},
 		})
 		return err
@@ -165,16 +351,155 @@ func (c *HelpCommand) showDetailedHelp(s *discordgo.Session, channelID, cmdName
 	return err
 }
 
+// Autocomplete suggests the names of the commands and subcommands matching what the user typed
+func (c *HelpCommand) Autocomplete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	query := ""
+	if focused := FocusedOption(i.ApplicationCommandData().Options); focused != nil {
+		query = strings.ToLower(strings.TrimPrefix(focused.StringValue(), "/"))
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, cmd := range c.registry.All() {
+		paths := []string{cmd.Name()}
+		if group, ok := cmd.(GroupCommand); ok {
+			for _, g := range group.SubcommandGroups() {
+				for _, sub := range g.Subcommands {
+					paths = append(paths, fmt.Sprintf("%s %s %s", cmd.Name(), g.Name, sub.Name))
+				}
+			}
+			for _, sub := range group.Subcommands() {
+				paths = append(paths, fmt.Sprintf("%s %s", cmd.Name(), sub.Name))
+			}
+		}
+
+		for _, path := range paths {
+			if strings.Contains(strings.ToLower(path), query) {
+				name := "/" + path
+				if _, ok := cmd.(ContextMenuCommand); ok {
+					name = path
+				}
+				choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+					Name:  name,
+					Value: path,
+				})
+			}
+		}
+	}
+
+	return choices, nil
+}
+
+// optionsField builds the embed field listing the options of a command, or nil if it has none
+func optionsField(options []*discordgo.ApplicationCommandOption) *discordgo.MessageEmbedField {
+	if len(options) == 0 {
+		return nil
+	}
+
+	var optionsList strings.Builder
+	for _, opt := range options {
+		required := ""
+		if opt.Required {
+			required = " *(required)*"
+		}
+		optionsList.WriteString(fmt.Sprintf("• **%s**%s - %s\n", opt.Name, required, opt.Description))
+	}
+
+	return &discordgo.MessageEmbedField{
+		Name:   "⚙️ Options",
+		Value:  optionsList.String(),
+		Inline: false,
+	}
+}
+
+// subcommandTree renders the subcommands and groups of a command as the lines of an indented tree
+func subcommandTree(cmd GroupCommand) []string {
+	var tree []string
+
+	for _, group := range cmd.SubcommandGroups() {
+		tree = append(tree, fmt.Sprintf("\u2003└ **%s** - %s", group.Name, group.Description))
+		for _, sub := range group.Subcommands {
+			tree = append(tree, fmt.Sprintf("\u2003\u2003└ `%s` - %s", sub.Name, sub.Description))
+		}
+	}
+
+	for _, sub := range cmd.Subcommands() {
+		tree = append(tree, fmt.Sprintf("\u2003└ `%s` - %s", sub.Name, sub.Description))
+	}
+
+	return tree
+}
+
+// splitField builds embed fields listing lines, a new field is started when the current one would get over
+// the length Discord accepts
+func splitField(name string, lines []string) []*discordgo.MessageEmbedField {
+	fields := []*discordgo.MessageEmbedField{}
+	var value strings.Builder
+
+	flush := func() {
+		fieldName := name
+		if len(fields) > 0 {
+			fieldName += " (continued)"
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   fieldName,
+			Value:  value.String(),
+			Inline: false,
+		})
+		value.Reset()
+	}
+
+	for _, line := range lines {
+		line = truncate(line, maxFieldLength-1) + "\n"
+		if value.Len() > 0 && utf8.RuneCountInString(value.String()+line) > maxFieldLength {
+			flush()
+		}
+		value.WriteString(line)
+	}
+	if value.Len() > 0 {
+		flush()
+	}
+
+	return fields
+}
+
+// findSubcommand finds a subcommand from its path relative to the command (e.g. ["set", "platform"])
+func findSubcommand(cmd GroupCommand, path []string) (*Subcommand, bool) {
+	switch len(path) {
+	case 1:
+		for _, sub := range cmd.Subcommands() {
+			if strings.EqualFold(sub.Name, path[0]) {
+				return sub, true
+			}
+		}
+	case 2:
+		for _, group := range cmd.SubcommandGroups() {
+			if !strings.EqualFold(group.Name, path[0]) {
+				continue
+			}
+			for _, sub := range group.Subcommands {
+				if strings.EqualFold(sub.Name, path[1]) {
+					return sub, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
 func (c *HelpCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	dmPermission := true
+
 	return &discordgo.ApplicationCommand{
-		Name:        c.Name(),
-		Description: c.Description(),
+		Name:         c.Name(),
+		Description:  c.Description(),
+		DMPermission: &dmPermission,
 		Options: []*discordgo.ApplicationCommandOption{
 			{
-				Type:        discordgo.ApplicationCommandOptionString,
-				Name:        "command",
-				Description: "The name of the command to get detailed help for",
-				Required:    false,
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "command",
+				Description:  "The name of the command or subcommand to get detailed help for (e.g. registrations export)",
+				Required:     false,
+				Autocomplete: true,
 			},
 		},
 	}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		limit int
+		want  string
+	}{
+		{"shorter", "hello", 10, "hello"},
+		{"at the limit", "hello", 5, "hello"},
+		{"longer", "hello world", 5, "hell…"},
+		{"multibyte", "ééééé", 3, "éé…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.s, tt.limit); got != tt.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitField(t *testing.T) {
+	tests := []struct {
+		name       string
+		lines      []string
+		wantFields int
+	}{
+		{"no lines", nil, 0},
+		{"short lines", []string{"/ping", "/help"}, 1},
+		{"many lines", slices.Repeat([]string{strings.Repeat("a", 99)}, 30), 3},
+		{"multibyte lines", slices.Repeat([]string{strings.Repeat("é", 99)}, 30), 3},
+		{"line over the limit", []string{strings.Repeat("a", 5000)}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := splitField("Commands", tt.lines)
+			if len(fields) != tt.wantFields {
+				t.Fatalf("got %d fields, want %d", len(fields), tt.wantFields)
+			}
+
+			lines := []string{}
+			for n, field := range fields {
+				if length := utf8.RuneCountInString(field.Value); length > maxFieldLength {
+					t.Errorf("field %d has %d characters, want at most %d", n, length, maxFieldLength)
+				}
+				wantName := "Commands"
+				if n > 0 {
+					wantName += " (continued)"
+				}
+				if field.Name != wantName {
+					t.Errorf("field %d is named %q, want %q", n, field.Name, wantName)
+				}
+				lines = append(lines, strings.Split(strings.TrimSuffix(field.Value, "\n"), "\n")...)
+			}
+
+			if len(lines) != len(tt.lines) && len(fields) > 0 {
+				t.Fatalf("got %d lines, want %d", len(lines), len(tt.lines))
+			}
+			for n, line := range lines {
+				if line != truncate(tt.lines[n], maxFieldLength-1) {
+					t.Errorf("line %d = %q, want %q", n, line, tt.lines[n])
+				}
+			}
+		})
+	}
+}
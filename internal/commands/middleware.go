@@ -0,0 +1,261 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/cooldown"
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/interaction"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// slowCommandThreshold is the duration after which a command is reported as slow
+// Discord invalidates interactions that are not acknowledged within 3 seconds
+const slowCommandThreshold = 2500 * time.Millisecond
+
+// Execution is a command being executed, passed along the middleware chain
+type Execution struct {
+	Context     context.Context // carries the correlation ID of the interaction
+	Command     Command
+	Path        string // invoked command, including subcommands (e.g. "registrations export"), "<command> component" or "<command> modal"
+	Session     *discordgo.Session
+	Interaction *discordgo.InteractionCreate
+	Options     []*discordgo.ApplicationCommandInteractionDataOption
+}
+
+// ExecuteFunc executes a command
+type ExecuteFunc func(e *Execution) error
+
+// Middleware wraps the execution of every command, it must call next to continue the chain
+type Middleware func(next ExecuteFunc) ExecuteFunc
+
+// GuildOnlyCommand is implemented by commands that can't be used in direct messages
+// Their ToApplicationCommand must set DMPermission to false so Discord hides them in direct messages
+type GuildOnlyCommand interface {
+	GuildOnly() bool
+}
+
+// PermissionedCommand is implemented by commands requiring permissions from the member using them
+type PermissionedCommand interface {
+	// RequiredPermissions returns the discordgo.Permission* flags the member must have
+	// They must also be declared in the DefaultMemberPermissions of ToApplicationCommand so Discord hides the command
+	RequiredPermissions() int64
+}
+
+// CooldownCommand is implemented by commands that can only be used a few times in a row, per user or per guild
+type CooldownCommand interface {
+	Cooldown() cooldown.Limit
+}
+
+// MetricsRecorder records the executions of commands
+type MetricsRecorder interface {
+	ObserveCommand(path string, duration time.Duration, err error)
+}
+
+// chain wraps an ExecuteFunc with middlewares, the first middleware is the outermost
+func chain(execute ExecuteFunc, middlewares []Middleware) ExecuteFunc {
+	for n := len(middlewares) - 1; n >= 0; n-- {
+		execute = middlewares[n](execute)
+	}
+	return execute
+}
+
+// RecoveryMiddleware turns a panic in a command into an IncidentError so the user gets an answer
+func RecoveryMiddleware(reporter *ErrorReporter) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(e *Execution) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = reporter.Report(e.Context, e.Session, e.Interaction, "/"+e.Path, r, debug.Stack())
+				}
+			}()
+			return next(e)
+		}
+	}
+}
+
+// TimingMiddleware reports the duration of every command and warns about slow ones
+func TimingMiddleware(logger *log.Logger) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(e *Execution) error {
+			start := time.Now()
+			err := next(e)
+			duration := time.Since(start)
+
+			entry := logger.WithContext(e.Context).WithFields(log.Fields{
+				"command":  e.Path,
+				"duration": duration,
+			})
+			if duration > slowCommandThreshold {
+				entry.Warn("Slow command")
+			} else {
+				entry.Debug("Command executed")
+			}
+
+			return err
+		}
+	}
+}
+
+// MetricsMiddleware records the duration and the result of every command
+func MetricsMiddleware(recorder MetricsRecorder) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(e *Execution) error {
+			start := time.Now()
+			err := next(e)
+			recorder.ObserveCommand(e.Path, time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// LoggingMiddleware logs every executed command
+func LoggingMiddleware(logger *log.Logger) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(e *Execution) error {
+			logger.WithContext(e.Context).WithFields(log.Fields{
+				"user":    interaction.User(e.Interaction.Interaction).Username,
+				"command": e.Path,
+				"options": e.Options,
+			}).Info("Executing command")
+
+			return next(e)
+		}
+	}
+}
+
+// GuildOnlyMiddleware rejects guild only commands used in direct messages
+func GuildOnlyMiddleware() Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(e *Execution) error {
+			if cmd, ok := e.Command.(GuildOnlyCommand); ok && cmd.GuildOnly() && e.Interaction.GuildID == "" {
+				respondWithError(e.Session, e.Interaction, "This command can only be used in a server.")
+				return nil
+			}
+			return next(e)
+		}
+	}
+}
+
+// PermissionMiddleware rejects commands used by members missing their required permissions
+func PermissionMiddleware() Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(e *Execution) error {
+			cmd, ok := e.Command.(PermissionedCommand)
+			if !ok || cmd.RequiredPermissions() == 0 {
+				return next(e)
+			}
+
+			member := e.Interaction.Member
+			if member == nil {
+				respondWithError(e.Session, e.Interaction, "This command can only be used in a server.")
+				return nil
+			}
+
+			required := cmd.RequiredPermissions()
+			if member.Permissions&discordgo.PermissionAdministrator == 0 && member.Permissions&required != required {
+				respondWithError(e.Session, e.Interaction, "You don't have the permissions to use this command.")
+				return nil
+			}
+
+			return next(e)
+		}
+	}
+}
+
+// RestrictionMiddleware rejects commands used outside the roles and channels guild admins restricted them to
+// Members who can manage the guild are never restricted, they can change the restrictions anyway
+func RestrictionMiddleware(restrictions database.PermissionStore, logger *log.Logger) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(e *Execution) error {
+			member := e.Interaction.Member
+			if e.Interaction.GuildID == "" || member == nil || member.Permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageGuild) != 0 {
+				return next(e)
+			}
+
+			rules, err := restrictions.GetCommandRestrictions(e.Context, e.Interaction.GuildID, strings.ToLower(e.Command.Name()))
+			if err != nil {
+				logger.WithContext(e.Context).WithError(err).WithField("command", e.Path).Error("Failed to get command restrictions from database")
+				respondWithError(e.Session, e.Interaction, "Failed to check the permissions of this command, please try again later.")
+				return nil
+			}
+
+			var roles, channels []string
+			for _, rule := range rules {
+				switch rule.Kind {
+				case database.RestrictionRole:
+					roles = append(roles, rule.TargetID)
+				case database.RestrictionChannel:
+					channels = append(channels, rule.TargetID)
+				}
+			}
+
+			if len(roles) > 0 && !containsAny(member.Roles, roles) {
+				respondWithError(e.Session, e.Interaction, "This command is restricted to "+mentionAll("<@&%s>", roles)+".")
+				return nil
+			}
+			if len(channels) > 0 && !slices.Contains(channels, e.Interaction.ChannelID) {
+				respondWithError(e.Session, e.Interaction, "This command can only be used in "+mentionAll("<#%s>", channels)+".")
+				return nil
+			}
+
+			return next(e)
+		}
+	}
+}
+
+// CooldownMiddleware rejects commands used more often than their cooldown allows
+// The uses are recorded in tracker, shared by every command, the components and modals of a command count apart from it
+func CooldownMiddleware(tracker *cooldown.Tracker) Middleware {
+	return func(next ExecuteFunc) ExecuteFunc {
+		return func(e *Execution) error {
+			cmd, ok := e.Command.(CooldownCommand)
+			if !ok {
+				return next(e)
+			}
+
+			limit := cmd.Cooldown()
+			key := limit.Key(e.Path, e.Interaction.GuildID, interaction.User(e.Interaction.Interaction).ID)
+
+			allowed, remaining := tracker.Take(key, limit)
+			if !allowed {
+				seconds := int(math.Ceil(remaining.Seconds()))
+				message := fmt.Sprintf("You're using this command too fast, try again in %ds.", seconds)
+				if limit.Scope != cooldown.ScopeUser {
+					message = fmt.Sprintf("This command has been used too often, try again in %ds.", seconds)
+				}
+
+				respondWithError(e.Session, e.Interaction, message)
+				return nil
+			}
+
+			return next(e)
+		}
+	}
+}
+
+// containsAny reports whether values contains one of wanted
+func containsAny(values, wanted []string) bool {
+	for _, value := range wanted {
+		if slices.Contains(values, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionAll formats every ID with format and joins them (e.g. "<@&1>, <@&2>")
+func mentionAll(format string, ids []string) string {
+	mentions := make([]string, len(ids))
+	for n, id := range ids {
+		mentions[n] = fmt.Sprintf(format, id)
+	}
+	return strings.Join(mentions, ", ")
+}
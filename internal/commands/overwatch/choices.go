@@ -0,0 +1,56 @@
+package overwatch
+
+import (
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxChoices is the maximum number of autocomplete suggestions Discord accepts
+const maxChoices = 25
+
+// choice is a value that can be suggested by autocomplete
+type choice struct {
+	name  string
+	value string
+}
+
+// matchChoices returns the choices whose name contains the query, ignoring case
+func matchChoices(choices []choice, query string) []*discordgo.ApplicationCommandOptionChoice {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	matches := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, c := range choices {
+		if !strings.Contains(strings.ToLower(c.name), query) {
+			continue
+		}
+
+		matches = append(matches, &discordgo.ApplicationCommandOptionChoice{
+			Name:  c.name,
+			Value: c.value,
+		})
+		if len(matches) == maxChoices {
+			break
+		}
+	}
+
+	return matches
+}
+
+// optionValue returns the string value of the first option of an interaction, or an empty string
+func optionValue(i *discordgo.InteractionCreate) string {
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		return ""
+	}
+	return options[0].StringValue()
+}
+
+// titleCase capitalizes the first letter of every word (e.g. "push escort" -> "Push Escort")
+func titleCase(s string) string {
+	words := strings.Fields(s)
+	for n, word := range words {
+		words[n] = strings.ToUpper(word[:1]) + word[1:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,212 @@
+package overwatch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/cooldown"
+	"github.com/borisjacquot/juno/internal/overwatch"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxAbilityLength is the maximum length of an ability description in the hero embed
+const maxAbilityLength = 200
+
+type HeroCommand struct {
+	owClient *overwatch.Client
+	logger   *log.Logger
+}
+
+func NewHeroCommand(owClient *overwatch.Client, logger *log.Logger) *HeroCommand {
+	return &HeroCommand{
+		owClient: owClient,
+		logger:   logger,
+	}
+}
+
+func (c *HeroCommand) Name() string {
+	return "hero"
+}
+
+func (c *HeroCommand) Description() string {
+	return "Display information about an Overwatch hero"
+}
+
+func (c *HeroCommand) Category() string {
+	return "Overwatch"
+}
+
+func (c *HeroCommand) Cooldown() cooldown.Limit {
+	return cooldown.PerUser(10, time.Minute)
+}
+
+func (c *HeroCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	// answer immediately
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		return err
+	}
+
+	query := optionValue(i)
+
+	key, err := c.findHeroKey(ctx, query)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to fetch heroes from Overwatch API")
+		return c.editResponse(s, i, "❌ Failed to fetch heroes. Please try again later.")
+	}
+	if key == "" {
+		return c.editResponse(s, i, fmt.Sprintf("❌ Unknown hero `%s`.", query))
+	}
+
+	hero, err := c.owClient.GetHero(ctx, key)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).WithField("hero", key).Error("Failed to fetch hero from Overwatch API")
+		return c.editResponse(s, i, "❌ Failed to fetch hero. Please try again later.")
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{buildHeroEmbed(hero)},
+	})
+	return err
+}
+
+// Autocomplete suggests the heroes matching what the user typed
+func (c *HeroCommand) Autocomplete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	heroes, err := c.owClient.GetHeroes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	choices := make([]choice, 0, len(heroes))
+	for _, hero := range heroes {
+		choices = append(choices, choice{name: hero.Name, value: hero.Key})
+	}
+
+	return matchChoices(choices, optionValue(i)), nil
+}
+
+// findHeroKey returns the key of the hero matching a key or a name typed by the user, or an empty string
+func (c *HeroCommand) findHeroKey(ctx context.Context, query string) (string, error) {
+	heroes, err := c.owClient.GetHeroes(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query = strings.TrimSpace(query)
+	for _, hero := range heroes {
+		if strings.EqualFold(hero.Key, query) || strings.EqualFold(hero.Name, query) {
+			return hero.Key, nil
+		}
+	}
+	return "", nil
+}
+
+// buildHeroEmbed builds the embed describing a hero
+func buildHeroEmbed(hero *overwatch.Hero) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("🦸 %s", hero.Name),
+		Description: hero.Description,
+		Color:       getRoleColor(hero.Role),
+		Thumbnail: &discordgo.MessageEmbedThumbnail{
+			URL: hero.Portrait,
+		},
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "🎭 Role",
+				Value:  titleCase(hero.Role),
+				Inline: true,
+			},
+			{
+				Name:   "❤️ Health",
+				Value:  formatHitPoints(hero.HitPoints),
+				Inline: true,
+			},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Data provided by Overfast API",
+		},
+	}
+
+	if hero.Location != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "📍 Location",
+			Value:  hero.Location,
+			Inline: true,
+		})
+	}
+
+	for _, ability := range hero.Abilities {
+		description := ability.Description
+		if len(description) > maxAbilityLength {
+			description = description[:maxAbilityLength-1] + "…"
+		}
+
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   fmt.Sprintf("✨ %s", ability.Name),
+			Value:  description,
+			Inline: false,
+		})
+	}
+
+	return embed
+}
+
+// formatHitPoints formats the health pool of a hero, only showing armor and shields when the hero has some
+func formatHitPoints(hp overwatch.HitPoints) string {
+	parts := []string{fmt.Sprintf("**%d** total", hp.Total)}
+	if hp.Health > 0 {
+		parts = append(parts, fmt.Sprintf("%d health", hp.Health))
+	}
+	if hp.Armor > 0 {
+		parts = append(parts, fmt.Sprintf("%d armor", hp.Armor))
+	}
+	if hp.Shields > 0 {
+		parts = append(parts, fmt.Sprintf("%d shields", hp.Shields))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// getRoleColor returns a color code based on the role of a hero
+func getRoleColor(role string) int {
+	switch strings.ToLower(role) {
+	case "tank":
+		return 0x3B82F6
+	case "damage":
+		return 0xEF4444
+	case "support":
+		return 0x22C55E
+	default:
+		return 0xF99E1A
+	}
+}
+
+func (c *HeroCommand) editResponse(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: stringPtr(message),
+	})
+	return err
+}
+
+func (c *HeroCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	dmPermission := true
+
+	return &discordgo.ApplicationCommand{
+		Name:         c.Name(),
+		Description:  c.Description(),
+		DMPermission: &dmPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "name",
+				Description:  "The hero to look up",
+				Required:     true,
+				Autocomplete: true,
+			},
+		},
+	}
+}
@@ -0,0 +1,166 @@
+package overwatch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/cooldown"
+	"github.com/borisjacquot/juno/internal/overwatch"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+type MapCommand struct {
+	owClient *overwatch.Client
+	logger   *log.Logger
+}
+
+func NewMapCommand(owClient *overwatch.Client, logger *log.Logger) *MapCommand {
+	return &MapCommand{
+		owClient: owClient,
+		logger:   logger,
+	}
+}
+
+func (c *MapCommand) Name() string {
+	return "map"
+}
+
+func (c *MapCommand) Description() string {
+	return "Display information about an Overwatch map"
+}
+
+func (c *MapCommand) Category() string {
+	return "Overwatch"
+}
+
+func (c *MapCommand) Cooldown() cooldown.Limit {
+	return cooldown.PerUser(10, time.Minute)
+}
+
+func (c *MapCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	// answer immediately
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+	if err != nil {
+		return err
+	}
+
+	query := strings.TrimSpace(optionValue(i))
+
+	maps, err := c.owClient.GetMaps(ctx)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to fetch maps from Overwatch API")
+		return c.editResponse(s, i, "❌ Failed to fetch maps. Please try again later.")
+	}
+
+	for _, m := range maps {
+		if strings.EqualFold(m.Name, query) {
+			_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+				Embeds: &[]*discordgo.MessageEmbed{buildMapEmbed(m)},
+			})
+			return err
+		}
+	}
+
+	return c.editResponse(s, i, fmt.Sprintf("❌ Unknown map `%s`.", query))
+}
+
+// Autocomplete suggests the maps matching what the user typed
+func (c *MapCommand) Autocomplete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	maps, err := c.owClient.GetMaps(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	choices := make([]choice, 0, len(maps))
+	for _, m := range maps {
+		choices = append(choices, choice{name: m.Name, value: m.Name})
+	}
+
+	return matchChoices(choices, optionValue(i)), nil
+}
+
+// buildMapEmbed builds the embed describing a map
+func buildMapEmbed(m overwatch.Map) *discordgo.MessageEmbed {
+	gameModes := make([]string, 0, len(m.GameModes))
+	for _, mode := range m.GameModes {
+		gameModes = append(gameModes, titleCase(strings.ReplaceAll(mode, "-", " ")))
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: fmt.Sprintf("🗺️ %s", m.Name),
+		Color: 0xF99E1A,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "🎯 Game Modes",
+				Value:  strings.Join(gameModes, ", "),
+				Inline: true,
+			},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Data provided by Overfast API",
+		},
+	}
+
+	if m.Location != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "📍 Location",
+			Value:  strings.TrimSpace(countryFlag(m.CountryCode) + " " + m.Location),
+			Inline: true,
+		})
+	}
+
+	if m.Screenshot != "" {
+		embed.Image = &discordgo.MessageEmbedImage{
+			URL: m.Screenshot,
+		}
+	}
+
+	return embed
+}
+
+// countryFlag converts a two-letter country code into its flag emoji
+func countryFlag(countryCode string) string {
+	if len(countryCode) != 2 {
+		return ""
+	}
+
+	var flag strings.Builder
+	for _, r := range strings.ToUpper(countryCode) {
+		if r < 'A' || r > 'Z' {
+			return ""
+		}
+		flag.WriteRune(0x1F1E6 + r - 'A')
+	}
+	return flag.String()
+}
+
+func (c *MapCommand) editResponse(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: stringPtr(message),
+	})
+	return err
+}
+
+func (c *MapCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	dmPermission := true
+
+	return &discordgo.ApplicationCommand{
+		Name:         c.Name(),
+		Description:  c.Description(),
+		DMPermission: &dmPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "name",
+				Description:  "The map to look up",
+				Required:     true,
+				Autocomplete: true,
+			},
+		},
+	}
+}
@@ -1,26 +1,30 @@
 package overwatch
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/borisjacquot/juno/internal/cooldown"
 	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/interaction"
 	"github.com/borisjacquot/juno/internal/overwatch"
 	"github.com/bwmarrin/discordgo"
 	log "github.com/sirupsen/logrus"
 )
 
 type ProfileCommand struct {
-	owClient *overwatch.Client
-	db       *database.Database
-	logger   *log.Logger
+	owClient      *overwatch.Client
+	registrations database.RegistrationStore
+	logger        *log.Logger
 }
 
-func NewProfileCommand(owClient *overwatch.Client, db *database.Database, logger *log.Logger) *ProfileCommand {
+func NewProfileCommand(owClient *overwatch.Client, registrations database.RegistrationStore, logger *log.Logger) *ProfileCommand {
 	return &ProfileCommand{
-		owClient: owClient,
-		db:       db,
-		logger:   logger,
+		owClient:      owClient,
+		registrations: registrations,
+		logger:        logger,
 	}
 }
 
@@ -36,7 +40,13 @@ func (c *ProfileCommand) Category() string {
 	return "Overwatch"
 }
 
-func (c *ProfileCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+// Cooldown limits the requests made to the Overwatch API, each profile is a new request
+// The "Overwatch profile" user command shares the uses of /profile
+func (c *ProfileCommand) Cooldown() cooldown.Limit {
+	return cooldown.PerUser(5, time.Minute).Shared("profile")
+}
+
+func (c *ProfileCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
 	// answer immediately
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
@@ -52,43 +62,50 @@ func (c *ProfileCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.Interac
 	if len(options) > 0 && options[0].UserValue(s) != nil {
 		targetUser = options[0].UserValue(s)
 	} else {
-		targetUser = i.Member.User
+		targetUser = interaction.User(i.Interaction)
 	}
 
-	c.logger.WithFields(log.Fields{
-		"requester": i.Member.User.Username,
+	return c.showProfile(ctx, s, i, targetUser)
+}
+
+// showProfile edits the deferred response of the interaction with the Overwatch profile of the target user
+func (c *ProfileCommand) showProfile(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, targetUser *discordgo.User) error {
+	c.logger.WithContext(ctx).WithFields(log.Fields{
+		"requester": interaction.User(i.Interaction).Username,
 		"target":    targetUser.Username,
 		"guild_id":  i.GuildID,
 	}).Info("Fetching profile for user")
 
 	// search for the user's BattleTag in the database
-	battleTag, err := c.db.GetUserBattleTag(i.GuildID, targetUser.ID)
+	registration, err := database.LookupRegistration(ctx, c.registrations, i.GuildID, targetUser.ID)
 	if err != nil {
-		c.logger.WithError(err).Error("Failed to get BattleTag from database")
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to get BattleTag from database")
 		return c.editResponse(s, i, "❌ Failed to retrieve BattleTag.")
 	}
 
-	if battleTag == "" {
+	if registration == nil {
 		username := targetUser.Username
-		if targetUser.ID == i.Member.User.ID {
+		if targetUser.ID == interaction.User(i.Interaction).ID {
 			return c.editResponse(s, i, "❌ You haven't registered your BattleTag yet. Use `/register` to link your Overwatch account.")
 		}
 		return c.editResponse(s, i, fmt.Sprintf("❌ **%s** hasn't registered their BattleTag yet.", username))
 	}
 
+	battleTag := registration.BattleTag
+
 	// get ow stats from the API
-	player, err := c.owClient.GetPlayer(battleTag)
+	player, err := c.owClient.GetPlayer(ctx, battleTag)
 	if err != nil {
-		c.logger.WithError(err).Error("Failed to fetch player profile from Overwatch API")
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to fetch player profile from Overwatch API")
 		return c.editResponse(s, i, "❌ Failed to fetch player profile. Please try again later.")
 	}
 
-	c.logger.WithFields(log.Fields{
+	c.logger.WithContext(ctx).WithFields(log.Fields{
 		"battletag": battleTag,
-		"name":      player.Name,
+		"player":    player.Name,
 	}).Debug("Successfully fetched player profile from Overwatch API")
 
-	embed := c.buildProfileEmbed(player, targetUser, battleTag)
+	embed := c.buildProfileEmbed(player, targetUser, registration)
 
 	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{embed},
@@ -97,13 +114,14 @@ func (c *ProfileCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.Interac
 	return err
 }
 
-func (c *ProfileCommand) buildProfileEmbed(player *overwatch.Player, discordUser *discordgo.User, battleTag string) *discordgo.MessageEmbed {
-	displayBattleTag := convertToDisplayFormat(battleTag)
+func (c *ProfileCommand) buildProfileEmbed(player *overwatch.Player, discordUser *discordgo.User, registration *database.UserRegistration) *discordgo.MessageEmbed {
+	displayBattleTag := overwatch.ToDisplayFormat(registration.BattleTag)
+	competitive := player.Competitive.Platform(registration.Platform)
 
 	embed := &discordgo.MessageEmbed{
 		Title:       fmt.Sprintf("📊 Overwatch Profile - %s", player.Name),
 		Description: fmt.Sprintf("<@%s>'s profile", discordUser.ID),
-		Color:       getRankColor(player),
+		Color:       getRankColor(competitive),
 		Thumbnail: &discordgo.MessageEmbedThumbnail{
 			URL: player.Avatar,
 		},
@@ -129,6 +147,15 @@ func (c *ProfileCommand) buildProfileEmbed(player *overwatch.Player, discordUser
 		},
 	}
 
+	// add preferred roles if the user filled them
+	if roles := registration.RoleList(); len(roles) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   "🎯 Preferred Roles",
+			Value:  titleCase(strings.Join(roles, ", ")),
+			Inline: true,
+		})
+	}
+
 	// add namecard image if available
 	if player.NameCard != "" {
 		embed.Image = &discordgo.MessageEmbedImage{
@@ -136,17 +163,17 @@ func (c *ProfileCommand) buildProfileEmbed(player *overwatch.Player, discordUser
 		}
 	}
 
-	// add PC competitive ranks
-	if player.Competitive.PC.Season > 0 {
+	// add competitive ranks of the platform the user registered with
+	if competitive.Season > 0 {
 		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 			Name:   "🏆 Competitive Season",
-			Value:  fmt.Sprintf("Season %d", player.Competitive.PC.Season),
+			Value:  fmt.Sprintf("Season %d", competitive.Season),
 			Inline: false,
 		})
 
 		// Tank
-		if player.Competitive.PC.Tank.Division != "" {
-			tankRank := formatRank(player.Competitive.PC.Tank)
+		if competitive.Tank.Division != "" {
+			tankRank := formatRank(competitive.Tank)
 			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 				Name:   "🛡️ Tank",
 				Value:  tankRank,
@@ -155,8 +182,8 @@ func (c *ProfileCommand) buildProfileEmbed(player *overwatch.Player, discordUser
 		}
 
 		// Damage
-		if player.Competitive.PC.Damage.Division != "" {
-			damageRank := formatRank(player.Competitive.PC.Damage)
+		if competitive.Damage.Division != "" {
+			damageRank := formatRank(competitive.Damage)
 			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 				Name:   "⚔️ Damage",
 				Value:  damageRank,
@@ -165,8 +192,8 @@ func (c *ProfileCommand) buildProfileEmbed(player *overwatch.Player, discordUser
 		}
 
 		// Support
-		if player.Competitive.PC.Support.Division != "" {
-			supportRank := formatRank(player.Competitive.PC.Support)
+		if competitive.Support.Division != "" {
+			supportRank := formatRank(competitive.Support)
 			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 				Name:   "💚 Support",
 				Value:  supportRank,
@@ -175,8 +202,8 @@ func (c *ProfileCommand) buildProfileEmbed(player *overwatch.Player, discordUser
 		}
 
 		// Open Queue (si disponible)
-		if player.Competitive.PC.Open.Division != "" {
-			openRank := formatRank(player.Competitive.PC.Open)
+		if competitive.Open.Division != "" {
+			openRank := formatRank(competitive.Open)
 			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 				Name:   "🌐 Open Queue",
 				Value:  openRank,
@@ -233,8 +260,8 @@ func getDivisionEmoji(division string) string {
 }
 
 // getRankColor returns a color code based on the player's highest competitive rank
-func getRankColor(player *overwatch.Player) int {
-	highestRank := getHighestRank(player)
+func getRankColor(competitive overwatch.CompetitivePlatformStats) int {
+	highestRank := getHighestRank(competitive)
 
 	switch {
 	case strings.Contains(strings.ToLower(highestRank), "champion"):
@@ -259,12 +286,12 @@ func getRankColor(player *overwatch.Player) int {
 }
 
 // getHighestRank determines the player's highest competitive rank across all roles
-func getHighestRank(player *overwatch.Player) string {
+func getHighestRank(competitive overwatch.CompetitivePlatformStats) string {
 	ranks := []string{
-		player.Competitive.PC.Tank.Division,
-		player.Competitive.PC.Damage.Division,
-		player.Competitive.PC.Support.Division,
-		player.Competitive.PC.Open.Division,
+		competitive.Tank.Division,
+		competitive.Damage.Division,
+		competitive.Support.Division,
+		competitive.Open.Division,
 	}
 
 	rankOrder := map[string]int{
@@ -306,9 +333,12 @@ func (c *ProfileCommand) editResponse(s *discordgo.Session, i *discordgo.Interac
 }
 
 func (c *ProfileCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	dmPermission := true
+
 	return &discordgo.ApplicationCommand{
-		Name:        c.Name(),
-		Description: c.Description(),
+		Name:         c.Name(),
+		Description:  c.Description(),
+		DMPermission: &dmPermission,
 		Options: []*discordgo.ApplicationCommandOption{
 			{
 				Type:        discordgo.ApplicationCommandOptionUser,
@@ -320,19 +350,6 @@ func (c *ProfileCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
 	}
 }
 
-// convertToDisplayFormat converts a BattleTag from "Pseudo-1234" to "Pseudo#1234"
-func convertToDisplayFormat(battleTag string) string {
-	// Convertir Pseudo-1234 en Pseudo#1234 pour l'affichage
-	if len(battleTag) > 0 {
-		for i := len(battleTag) - 1; i >= 0; i-- {
-			if battleTag[i] == '-' {
-				return battleTag[:i] + "#" + battleTag[i+1:]
-			}
-		}
-	}
-	return battleTag
-}
-
 func stringPtr(s string) *string {
 	return &s
 }
@@ -0,0 +1,72 @@
+package overwatch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/borisjacquot/juno/internal/cooldown"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ProfileUserCommand is the "Overwatch profile" user context menu command, showing the same profile as /profile
+type ProfileUserCommand struct {
+	profile *ProfileCommand
+}
+
+func NewProfileUserCommand(profile *ProfileCommand) *ProfileUserCommand {
+	return &ProfileUserCommand{
+		profile: profile,
+	}
+}
+
+func (c *ProfileUserCommand) Name() string {
+	return "Overwatch profile"
+}
+
+func (c *ProfileUserCommand) Description() string {
+	return "Display the Overwatch profile of the user you right clicked"
+}
+
+func (c *ProfileUserCommand) Category() string {
+	return "Overwatch"
+}
+
+// Cooldown is the one of /profile, the uses of both commands are counted together
+func (c *ProfileUserCommand) Cooldown() cooldown.Limit {
+	return c.profile.Cooldown()
+}
+
+func (c *ProfileUserCommand) CommandType() discordgo.ApplicationCommandType {
+	return discordgo.UserApplicationCommand
+}
+
+func (c *ProfileUserCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	data := i.ApplicationCommandData()
+	if data.Resolved == nil || data.Resolved.Users[data.TargetID] == nil {
+		return fmt.Errorf("target user not found")
+	}
+
+	// only the requester sees the profile, it's not a message they typed
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.profile.showProfile(ctx, s, i, data.Resolved.Users[data.TargetID])
+}
+
+func (c *ProfileUserCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	dmPermission := true
+
+	// context menu commands have no description
+	return &discordgo.ApplicationCommand{
+		Name:         c.Name(),
+		Type:         c.CommandType(),
+		DMPermission: &dmPermission,
+	}
+}
@@ -0,0 +1,360 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/interaction"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// PermissionsCommand lets guild admins restrict commands to roles and channels
+type PermissionsCommand struct {
+	registry     *Registry
+	restrictions database.PermissionStore
+	logger       *log.Logger
+}
+
+func NewPermissionsCommand(registry *Registry, restrictions database.PermissionStore, logger *log.Logger) *PermissionsCommand {
+	return &PermissionsCommand{
+		registry:     registry,
+		restrictions: restrictions,
+		logger:       logger,
+	}
+}
+
+func (c *PermissionsCommand) Name() string {
+	return "permissions"
+}
+
+func (c *PermissionsCommand) Description() string {
+	return "Restrict commands to roles or channels in this server"
+}
+
+func (c *PermissionsCommand) Category() string {
+	return "Admin"
+}
+
+func (c *PermissionsCommand) GuildOnly() bool {
+	return true
+}
+
+func (c *PermissionsCommand) RequiredPermissions() int64 {
+	return discordgo.PermissionManageGuild
+}
+
+func (c *PermissionsCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	return ExecuteSubcommand(ctx, c, s, i)
+}
+
+func (c *PermissionsCommand) Subcommands() []*Subcommand {
+	return []*Subcommand{
+		{
+			Name:        "list",
+			Description: "List the restrictions of this server",
+			Options: []*discordgo.ApplicationCommandOption{
+				c.commandOption(false),
+			},
+			Handler: c.list,
+		},
+		{
+			Name:        "reset",
+			Description: "Remove every restriction of a command",
+			Options: []*discordgo.ApplicationCommandOption{
+				c.commandOption(true),
+			},
+			Handler: c.reset,
+		},
+	}
+}
+
+func (c *PermissionsCommand) SubcommandGroups() []*SubcommandGroup {
+	roleOption := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionRole,
+		Name:        "role",
+		Description: "The role",
+		Required:    true,
+	}
+	channelOption := &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionChannel,
+		Name:         "channel",
+		Description:  "The channel",
+		Required:     true,
+		ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews},
+	}
+
+	return []*SubcommandGroup{
+		{
+			Name:        "role",
+			Description: "Restrict commands to the members of roles",
+			Subcommands: []*Subcommand{
+				{
+					Name:        "add",
+					Description: "Only let the members of a role use a command",
+					Options:     []*discordgo.ApplicationCommandOption{c.commandOption(true), roleOption},
+					Handler:     c.add(database.RestrictionRole),
+				},
+				{
+					Name:        "remove",
+					Description: "Stop restricting a command to a role",
+					Options:     []*discordgo.ApplicationCommandOption{c.commandOption(true), roleOption},
+					Handler:     c.remove(database.RestrictionRole),
+				},
+			},
+		},
+		{
+			Name:        "channel",
+			Description: "Restrict commands to channels",
+			Subcommands: []*Subcommand{
+				{
+					Name:        "add",
+					Description: "Only allow a command in a channel",
+					Options:     []*discordgo.ApplicationCommandOption{c.commandOption(true), channelOption},
+					Handler:     c.add(database.RestrictionChannel),
+				},
+				{
+					Name:        "remove",
+					Description: "Stop restricting a command to a channel",
+					Options:     []*discordgo.ApplicationCommandOption{c.commandOption(true), channelOption},
+					Handler:     c.remove(database.RestrictionChannel),
+				},
+			},
+		},
+	}
+}
+
+// commandOption is the option naming the restricted command
+func (c *PermissionsCommand) commandOption(required bool) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:         discordgo.ApplicationCommandOptionString,
+		Name:         "command",
+		Description:  "The command (e.g. profile)",
+		Required:     required,
+		Autocomplete: true,
+	}
+}
+
+// add returns the handler restricting a command to a role or a channel
+func (c *PermissionsCommand) add(kind string) SubcommandHandler {
+	return func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+		cmd, targetID, err := c.parseOptions(s, kind, options)
+		if err != nil {
+			return c.respond(s, i, fmt.Sprintf("❌ %v", err))
+		}
+
+		if err := c.restrictions.AddCommandRestriction(ctx, i.GuildID, strings.ToLower(cmd.Name()), kind, targetID); err != nil {
+			c.logger.WithContext(ctx).WithError(err).Error("Failed to add command restriction")
+			return c.respond(s, i, "❌ Failed to save the restriction. Please try again later.")
+		}
+
+		c.logger.WithContext(ctx).WithFields(log.Fields{
+			"user":      interaction.User(i.Interaction).Username,
+			"guild_id":  i.GuildID,
+			"command":   cmd.Name(),
+			"kind":      kind,
+			"target_id": targetID,
+		}).Info("Command restriction added")
+
+		return c.respond(s, i, fmt.Sprintf("✅ **%s** is now restricted to %s. Members with the Manage Server permission can still use it.", commandLabel(cmd), mention(kind, targetID)))
+	}
+}
+
+// remove returns the handler removing the restriction of a command to a role or a channel
+func (c *PermissionsCommand) remove(kind string) SubcommandHandler {
+	return func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+		cmd, targetID, err := c.parseOptions(s, kind, options)
+		if err != nil {
+			return c.respond(s, i, fmt.Sprintf("❌ %v", err))
+		}
+
+		err = c.restrictions.RemoveCommandRestriction(ctx, i.GuildID, strings.ToLower(cmd.Name()), kind, targetID)
+		if errors.Is(err, database.ErrNotFound) {
+			return c.respond(s, i, fmt.Sprintf("❌ **%s** isn't restricted to %s.", commandLabel(cmd), mention(kind, targetID)))
+		}
+		if err != nil {
+			c.logger.WithContext(ctx).WithError(err).Error("Failed to remove command restriction")
+			return c.respond(s, i, "❌ Failed to remove the restriction. Please try again later.")
+		}
+
+		c.logger.WithContext(ctx).WithFields(log.Fields{
+			"user":      interaction.User(i.Interaction).Username,
+			"guild_id":  i.GuildID,
+			"command":   cmd.Name(),
+			"kind":      kind,
+			"target_id": targetID,
+		}).Info("Command restriction removed")
+
+		return c.respond(s, i, fmt.Sprintf("✅ **%s** is no longer restricted to %s.", commandLabel(cmd), mention(kind, targetID)))
+	}
+}
+
+// reset removes every restriction of a command
+func (c *PermissionsCommand) reset(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	cmd, err := c.findCommand(options)
+	if err != nil {
+		return c.respond(s, i, fmt.Sprintf("❌ %v", err))
+	}
+
+	removed, err := c.restrictions.ClearCommandRestrictions(ctx, i.GuildID, strings.ToLower(cmd.Name()))
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to clear command restrictions")
+		return c.respond(s, i, "❌ Failed to remove the restrictions. Please try again later.")
+	}
+
+	c.logger.WithContext(ctx).WithFields(log.Fields{
+		"user":     interaction.User(i.Interaction).Username,
+		"guild_id": i.GuildID,
+		"command":  cmd.Name(),
+		"removed":  removed,
+	}).Info("Command restrictions cleared")
+
+	return c.respond(s, i, fmt.Sprintf("✅ Removed %d restrictions of **%s**, everyone can use it again.", removed, commandLabel(cmd)))
+}
+
+// list shows the restrictions of the guild, or of a single command
+func (c *PermissionsCommand) list(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	var (
+		restrictions []database.CommandRestriction
+		err          error
+	)
+
+	if len(options) > 0 {
+		cmd, findErr := c.findCommand(options)
+		if findErr != nil {
+			return c.respond(s, i, fmt.Sprintf("❌ %v", findErr))
+		}
+		restrictions, err = c.restrictions.GetCommandRestrictions(ctx, i.GuildID, strings.ToLower(cmd.Name()))
+	} else {
+		restrictions, err = c.restrictions.GetGuildRestrictions(ctx, i.GuildID)
+	}
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to get command restrictions from database")
+		return c.respond(s, i, "❌ Failed to retrieve the restrictions.")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title: "🔒 Command Restrictions",
+		Color: 0xD183C9,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Members with the Manage Server permission are never restricted",
+		},
+	}
+
+	if len(restrictions) == 0 {
+		embed.Description = "No command is restricted, everyone can use every command."
+	}
+
+	// one field per command, restrictions are ordered by command
+	for n := 0; n < len(restrictions); {
+		command := restrictions[n].Command
+
+		var roles, channels []string
+		for ; n < len(restrictions) && restrictions[n].Command == command; n++ {
+			if restrictions[n].Kind == database.RestrictionRole {
+				roles = append(roles, restrictions[n].TargetID)
+			} else {
+				channels = append(channels, restrictions[n].TargetID)
+			}
+		}
+
+		var value strings.Builder
+		if len(roles) > 0 {
+			value.WriteString("Roles: " + mentionAll("<@&%s>", roles) + "\n")
+		}
+		if len(channels) > 0 {
+			value.WriteString("Channels: " + mentionAll("<#%s>", channels) + "\n")
+		}
+
+		label := command
+		if cmd, ok := c.registry.Get(command); ok {
+			label = commandLabel(cmd)
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  label,
+			Value: value.String(),
+		})
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+			Flags:  discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// parseOptions returns the command and the ID of the role or channel given to a role or channel subcommand
+func (c *PermissionsCommand) parseOptions(s *discordgo.Session, kind string, options []*discordgo.ApplicationCommandInteractionDataOption) (Command, string, error) {
+	cmd, err := c.findCommand(options)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, option := range options {
+		if option.Name == kind {
+			// the role and channel options carry their ID
+			return cmd, option.Value.(string), nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("missing %s", kind)
+}
+
+// findCommand returns the command named by the command option
+func (c *PermissionsCommand) findCommand(options []*discordgo.ApplicationCommandInteractionDataOption) (Command, error) {
+	for _, option := range options {
+		if option.Name != "command" {
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.TrimSpace(option.StringValue()), "/")
+		cmd, ok := c.registry.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown command `%s`", name)
+		}
+		return cmd, nil
+	}
+
+	return nil, fmt.Errorf("missing command")
+}
+
+// Autocomplete suggests the names of the commands
+func (c *PermissionsCommand) Autocomplete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	return commandChoices(c.registry, i), nil
+}
+
+func (c *PermissionsCommand) respond(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// mention formats the mention of the role or channel of a restriction
+func mention(kind, targetID string) string {
+	if kind == database.RestrictionRole {
+		return fmt.Sprintf("<@&%s>", targetID)
+	}
+	return fmt.Sprintf("<#%s>", targetID)
+}
+
+func (c *PermissionsCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	permissions := int64(discordgo.PermissionManageGuild)
+	dmPermission := false
+
+	return &discordgo.ApplicationCommand{
+		Name:                     c.Name(),
+		Description:              c.Description(),
+		DefaultMemberPermissions: &permissions,
+		DMPermission:             &dmPermission,
+		Options:                  SubcommandOptions(c),
+	}
+}
@@ -0,0 +1,111 @@
+package commands_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/borisjacquot/juno/internal/commands"
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/database/memory"
+	"github.com/bwmarrin/discordgo"
+)
+
+// groupSubcommand returns the option invoking the subcommand name of a subcommand group
+func groupSubcommand(group, name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Name: group,
+		Type: discordgo.ApplicationCommandOptionSubCommandGroup,
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{
+				Name:    name,
+				Type:    discordgo.ApplicationCommandOptionSubCommand,
+				Options: options,
+			},
+		},
+	}
+}
+
+func roleOption(roleID string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Name:  "role",
+		Type:  discordgo.ApplicationCommandOptionRole,
+		Value: roleID,
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	const roleID = "500000000000000001"
+
+	// the steps run in order against the same store
+	steps := []struct {
+		name        string
+		subcommand  *discordgo.ApplicationCommandInteractionDataOption
+		wantMessage string
+		wantRoles   []string
+	}{
+		{
+			name:        "add",
+			subcommand:  groupSubcommand("role", "add", stringOption("command", "register"), roleOption(roleID)),
+			wantMessage: "is now restricted to <@&" + roleID + ">",
+			wantRoles:   []string{roleID},
+		},
+		{
+			name:        "add again",
+			subcommand:  groupSubcommand("role", "add", stringOption("command", "/register"), roleOption(roleID)),
+			wantMessage: "is now restricted",
+			wantRoles:   []string{roleID},
+		},
+		{
+			name:        "add to an unknown command",
+			subcommand:  groupSubcommand("role", "add", stringOption("command", "unknown"), roleOption(roleID)),
+			wantMessage: "unknown command `unknown`",
+			wantRoles:   []string{roleID},
+		},
+		{
+			name:        "remove",
+			subcommand:  groupSubcommand("role", "remove", stringOption("command", "register"), roleOption(roleID)),
+			wantMessage: "is no longer restricted",
+		},
+		{
+			name:        "remove again",
+			subcommand:  groupSubcommand("role", "remove", stringOption("command", "register"), roleOption(roleID)),
+			wantMessage: "isn't restricted",
+		},
+	}
+
+	ctx := context.Background()
+	store := memory.New()
+	logger := newLogger()
+	registry := commands.NewRegistry(logger)
+	if err := registry.Register(commands.NewRegisterCommand(store, logger)); err != nil {
+		t.Fatalf("failed to register command: %v", err)
+	}
+	cmd := commands.NewPermissionsCommand(registry, store, logger)
+	s, discord := newSession(t)
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if err := cmd.ExecuteSlash(ctx, s, slashInteraction("permissions", step.subcommand)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(discord.last(), step.wantMessage) {
+				t.Errorf("message = %q, want %q", discord.last(), step.wantMessage)
+			}
+
+			restrictions, err := store.GetCommandRestrictions(ctx, testGuildID, "register")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			roles := []string{}
+			for _, restriction := range restrictions {
+				if restriction.Kind == database.RestrictionRole {
+					roles = append(roles, restriction.TargetID)
+				}
+			}
+			if strings.Join(roles, ",") != strings.Join(step.wantRoles, ",") {
+				t.Errorf("roles = %v, want %v", roles, step.wantRoles)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"context"
+	"github.com/borisjacquot/juno/internal/interaction"
 	"github.com/bwmarrin/discordgo"
 	log "github.com/sirupsen/logrus"
 )
@@ -27,8 +29,8 @@ func (c *PingCommand) Category() string {
 	return "General"
 }
 
-func (c *PingCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
-	c.logger.WithField("user", i.Member.User.Username).Debug("Slash command ping executed")
+func (c *PingCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	c.logger.WithContext(ctx).WithField("user", interaction.User(i.Interaction).Username).Debug("Slash command ping executed")
 
 	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -39,8 +41,11 @@ func (c *PingCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.Interactio
 }
 
 func (c *PingCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	dmPermission := true
+
 	return &discordgo.ApplicationCommand{
-		Name:        c.Name(),
-		Description: c.Description(),
+		Name:         c.Name(),
+		Description:  c.Description(),
+		DMPermission: &dmPermission,
 	}
 }
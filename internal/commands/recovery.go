@@ -0,0 +1,121 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/interaction"
+	"github.com/borisjacquot/juno/internal/logging"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxStackLength is the length of the stack trace posted to the error channel, embeds are limited to 4096 characters
+const maxStackLength = 3800
+
+// IncidentError is returned when a command panicked, its ID is shown to the user and logged with the stack trace
+type IncidentError struct {
+	ID string
+}
+
+func (e *IncidentError) Error() string {
+	return fmt.Sprintf("an internal error occurred (incident `%s`), please report it to the bot owner", e.ID)
+}
+
+// ErrorReporter logs panics with an incident ID and posts them to an error channel
+type ErrorReporter struct {
+	channelID string
+	logger    *log.Logger
+}
+
+// NewErrorReporter creates a reporter, panics are posted to channelID unless it's empty
+func NewErrorReporter(channelID string, logger *log.Logger) *ErrorReporter {
+	return &ErrorReporter{
+		channelID: channelID,
+		logger:    logger,
+	}
+}
+
+// Report logs a panic that happened while handling an interaction and returns the incident to show the user
+func (r *ErrorReporter) Report(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, source string, value any, stack []byte) *IncidentError {
+	incident := &IncidentError{ID: newIncidentID()}
+
+	r.logger.WithContext(ctx).WithFields(log.Fields{
+		"incident": incident.ID,
+		"source":   source,
+		"user":     interaction.User(i.Interaction).Username,
+		"guild":    i.GuildID,
+		"channel":  i.ChannelID,
+		"panic":    value,
+		"stack":    string(stack),
+	}).Error("Recovered from panic while handling interaction")
+
+	if r.channelID != "" {
+		r.postIncident(ctx, s, i, incident, source, value, stack)
+	}
+
+	return incident
+}
+
+// postIncident sends the details of an incident to the error channel
+func (r *ErrorReporter) postIncident(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, incident *IncidentError, source string, value any, stack []byte) {
+	trace := string(stack)
+	if len(trace) > maxStackLength {
+		trace = trace[:maxStackLength] + "\n…"
+	}
+
+	location := "Direct messages"
+	if i.GuildID != "" {
+		location = fmt.Sprintf("Guild `%s`, channel <#%s>", i.GuildID, i.ChannelID)
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       fmt.Sprintf("💥 Incident %s", incident.ID),
+		Description: fmt.Sprintf("```\n%s\n```", trace),
+		Color:       0xE74C3C,
+		Timestamp:   time.Now().Format(time.RFC3339),
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Source",
+				Value:  source,
+				Inline: true,
+			},
+			{
+				Name:   "User",
+				Value:  fmt.Sprintf("%s (`%s`)", interaction.User(i.Interaction).Username, interaction.User(i.Interaction).ID),
+				Inline: true,
+			},
+			{
+				Name:   "Location",
+				Value:  location,
+				Inline: false,
+			},
+			{
+				Name:   "Correlation ID",
+				Value:  fmt.Sprintf("`%s`", logging.CorrelationID(ctx)),
+				Inline: true,
+			},
+			{
+				Name:   "Panic",
+				Value:  fmt.Sprintf("`%v`", value),
+				Inline: false,
+			},
+		},
+	}
+
+	if _, err := s.ChannelMessageSendEmbed(r.channelID, embed); err != nil {
+		r.logger.WithContext(ctx).WithError(err).WithField("incident", incident.ID).Error("Failed to post incident to error channel")
+	}
+}
+
+// newIncidentID returns a short random identifier
+func newIncidentID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(b)
+}
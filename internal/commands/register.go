@@ -1,25 +1,26 @@
 package commands
 
 import (
+	"context"
 	"fmt"
-	"regexp"
+	"strings"
 
 	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/interaction"
+	"github.com/borisjacquot/juno/internal/overwatch"
 	"github.com/bwmarrin/discordgo"
 	log "github.com/sirupsen/logrus"
 )
 
-var battleTagRegex = regexp.MustCompile(`^[a-zA-Z0-9]{3,12}#[0-9]{4,5}$`)
-
 type RegisterCommand struct {
-	db     *database.Database
-	logger *log.Logger
+	registrations database.RegistrationStore
+	logger        *log.Logger
 }
 
-func NewRegisterCommand(db *database.Database, logger *log.Logger) *RegisterCommand {
+func NewRegisterCommand(registrations database.RegistrationStore, logger *log.Logger) *RegisterCommand {
 	return &RegisterCommand{
-		db:     db,
-		logger: logger,
+		registrations: registrations,
+		logger:        logger,
 	}
 }
 
@@ -35,26 +36,36 @@ func (c *RegisterCommand) Category() string {
 	return "General"
 }
 
-func (c *RegisterCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error {
+func (c *RegisterCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
 	options := i.ApplicationCommandData().Options
 
+	// without a BattleTag, open the registration form
 	if len(options) == 0 {
-		return fmt.Errorf("you must provide your BattleTag as an argument (e.g. /register battleTag:Player#1234)")
+		return c.openForm(ctx, s, i)
 	}
 
-	battleTag := options[0].StringValue()
+	return c.register(ctx, s, i, options[0].StringValue(), nil)
+}
+
+// preferences are the optional preferences filled in the registration form
+type preferences struct {
+	platform string
+	roles    []string
+}
 
+// register links the BattleTag to the user, and saves their preferences when given
+func (c *RegisterCommand) register(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, battleTag string, prefs *preferences) error {
 	// validate BattleTag format
-	if !c.isValidBattleTag(battleTag) {
+	if !overwatch.IsValidBattleTag(battleTag) {
 		return c.respondError(s, i, "❌ Invalid BattleTag format. It should be in the format `Player#1234`")
 	}
 
 	// convert to overwatch api format (Player-1234)
-	battleTagForAPI := c.convertBattleTagFormat(battleTag)
+	battleTagForAPI := overwatch.ToAPIFormat(battleTag)
 
-	c.logger.WithFields(log.Fields{
-		"user":      i.Member.User.Username,
-		"battleTag": battleTag,
+	c.logger.WithContext(ctx).WithFields(log.Fields{
+		"user":      interaction.User(i.Interaction).Username,
+		"battletag": battleTag,
 		"guild_id":  i.GuildID,
 	}).Info("Registering BattleTag for user")
 
@@ -70,15 +81,29 @@ func (c *RegisterCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.Intera
 	}
 
 	// save the BattleTag in the database
-	err = c.db.RegisterUser(i.GuildID, i.Member.User.ID, battleTagForAPI)
+	err = c.registrations.RegisterUser(ctx, i.GuildID, interaction.User(i.Interaction).ID, battleTagForAPI)
 	if err != nil {
-		c.logger.WithError(err).Error("Failed to register BattleTag in database")
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to register BattleTag in database")
 		return c.editResponse(s, i, "❌ Failed to register your BattleTag. Please try again later.")
 	}
 
+	if prefs != nil {
+		err = c.registrations.SetUserPreferences(ctx, i.GuildID, interaction.User(i.Interaction).ID, prefs.platform, prefs.roles)
+		if err != nil {
+			c.logger.WithContext(ctx).WithError(err).Error("Failed to save user preferences in database")
+			return c.editResponse(s, i, "❌ Your BattleTag was registered but your preferences couldn't be saved. Please try again later.")
+		}
+	}
+
+	description := fmt.Sprintf("Your Discord account has been linked to `%s`!", battleTag)
+	if interaction.InDM(i.Interaction) {
+		// registrations made in direct messages are global account links
+		description = fmt.Sprintf("Your Discord account has been linked to `%s` in every server where you didn't register another BattleTag!", battleTag)
+	}
+
 	embed := &discordgo.MessageEmbed{
 		Title:       "✅ BattleTag Registered",
-		Description: fmt.Sprintf("Your Discord account has been linked to `%s`!", battleTag),
+		Description: description,
 		Color:       0xD183C9,
 		Fields: []*discordgo.MessageEmbedField{
 			{
@@ -88,7 +113,7 @@ func (c *RegisterCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.Intera
 			},
 			{
 				Name:   "👤 Discord User",
-				Value:  i.Member.User.Username,
+				Value:  interaction.User(i.Interaction).Username,
 				Inline: true,
 			},
 		},
@@ -97,6 +122,30 @@ func (c *RegisterCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.Intera
 		},
 	}
 
+	if prefs != nil {
+		platform := "PC"
+		if prefs.platform != "" {
+			platform = titleCase(prefs.platform)
+		}
+		roles := "Any"
+		if len(prefs.roles) > 0 {
+			roles = titleCase(strings.Join(prefs.roles, ", "))
+		}
+
+		embed.Fields = append(embed.Fields,
+			&discordgo.MessageEmbedField{
+				Name:   "🖥️ Platform",
+				Value:  platform,
+				Inline: true,
+			},
+			&discordgo.MessageEmbedField{
+				Name:   "🎯 Preferred Roles",
+				Value:  roles,
+				Inline: true,
+			},
+		)
+	}
+
 	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{embed},
 	})
@@ -104,13 +153,180 @@ func (c *RegisterCommand) ExecuteSlash(s *discordgo.Session, i *discordgo.Intera
 	return err
 }
 
-func (c *RegisterCommand) isValidBattleTag(battleTag string) bool {
-	return battleTagRegex.MatchString(battleTag)
+// openForm opens the registration form, prefilled with the current registration of the user
+func (c *RegisterCommand) openForm(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	registration, err := database.LookupRegistration(ctx, c.registrations, i.GuildID, interaction.User(i.Interaction).ID)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Warn("Failed to get user registration, opening an empty form")
+	}
+
+	var battleTag, platform, roles string
+	if registration != nil {
+		battleTag = overwatch.ToDisplayFormat(registration.BattleTag)
+		platform = registration.Platform
+		roles = strings.Join(registration.RoleList(), ", ")
+	}
+
+	customID, err := EncodeCustomID(c.ModalPrefix())
+	if err != nil {
+		return err
+	}
+
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseModal,
+		Data: &discordgo.InteractionResponseData{
+			CustomID: customID,
+			Title:    "Link your Overwatch account",
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "battletag",
+							Label:       "BattleTag",
+							Style:       discordgo.TextInputShort,
+							Placeholder: "Player#1234",
+							Value:       battleTag,
+							Required:    true,
+							MinLength:   8,
+							MaxLength:   18,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "platform",
+							Label:       "Platform (PC or Console)",
+							Style:       discordgo.TextInputShort,
+							Placeholder: "Leave empty for PC",
+							Value:       platform,
+							Required:    false,
+							MaxLength:   20,
+						},
+					},
+				},
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.TextInput{
+							CustomID:    "roles",
+							Label:       "Preferred roles",
+							Style:       discordgo.TextInputShort,
+							Placeholder: "tank, damage, support",
+							Value:       roles,
+							Required:    false,
+							MaxLength:   50,
+						},
+					},
+				},
+			},
+		},
+	})
 }
 
-func (c *RegisterCommand) convertBattleTagFormat(battleTag string) string {
-	// Convertir Pseudo#1234 en Pseudo-1234 pour l'API Overwatch
-	return regexp.MustCompile(`#`).ReplaceAllString(battleTag, "-")
+// ModalPrefix returns the prefix of the custom ID of the registration form
+func (c *RegisterCommand) ModalPrefix() string {
+	return "register"
+}
+
+// HandleModal registers the user with the values of the registration form
+func (c *RegisterCommand) HandleModal(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, _ []string) error {
+	values := ModalValues(i.ModalSubmitData())
+
+	platform, err := parsePlatform(values["platform"])
+	if err != nil {
+		return c.respondError(s, i, fmt.Sprintf("❌ Invalid form: %v", err))
+	}
+
+	roles, err := parseRoles(values["roles"])
+	if err != nil {
+		return c.respondError(s, i, fmt.Sprintf("❌ Invalid form: %v", err))
+	}
+
+	return c.register(ctx, s, i, values["battletag"], &preferences{
+		platform: platform,
+		roles:    roles,
+	})
+}
+
+// parsePlatform converts the platform typed by a user, an empty value means PC
+func parsePlatform(value string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return "", nil
+	case "pc":
+		return database.PlatformPC, nil
+	case "console", "xbox", "playstation", "ps4", "ps5", "switch":
+		return database.PlatformConsole, nil
+	default:
+		return "", fmt.Errorf("unknown platform `%s`, it should be `PC` or `Console`", value)
+	}
+}
+
+// parseRoles converts the roles typed by a user (comma or space separated) to their canonical names
+func parseRoles(value string) ([]string, error) {
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ',' || r == ' ' || r == '/'
+	})
+
+	seen := make(map[string]bool)
+	roles := []string{}
+	for _, field := range fields {
+		var role string
+		switch field {
+		case "tank":
+			role = database.RoleTank
+		case "damage", "dps":
+			role = database.RoleDamage
+		case "support", "heal", "healer":
+			role = database.RoleSupport
+		default:
+			return nil, fmt.Errorf("unknown role `%s`, it should be `tank`, `damage` or `support`", field)
+		}
+
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	return roles, nil
+}
+
+// titleCase capitalizes the first letter of every word (e.g. "tank, support" -> "Tank, Support")
+func titleCase(s string) string {
+	words := strings.Split(s, " ")
+	for n, word := range words {
+		if word != "" {
+			words[n] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Autocomplete suggests the BattleTags the user already linked in other servers
+func (c *RegisterCommand) Autocomplete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) ([]*discordgo.ApplicationCommandOptionChoice, error) {
+	query := ""
+	if focused := FocusedOption(i.ApplicationCommandData().Options); focused != nil {
+		query = strings.ToLower(focused.StringValue())
+	}
+
+	battleTags, err := c.registrations.GetUserBattleTags(ctx, interaction.User(i.Interaction).ID)
+	if err != nil {
+		return nil, err
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, battleTag := range battleTags {
+		display := overwatch.ToDisplayFormat(battleTag)
+		if strings.Contains(strings.ToLower(display), query) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  display,
+				Value: display,
+			})
+		}
+	}
+
+	return choices, nil
 }
 
 func (c *RegisterCommand) respondError(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
@@ -135,15 +351,19 @@ func stringPtr(s string) *string {
 }
 
 func (c *RegisterCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	dmPermission := true
+
 	return &discordgo.ApplicationCommand{
-		Name:        c.Name(),
-		Description: c.Description(),
+		Name:         c.Name(),
+		Description:  c.Description(),
+		DMPermission: &dmPermission,
 		Options: []*discordgo.ApplicationCommandOption{
 			{
-				Type:        discordgo.ApplicationCommandOptionString,
-				Name:        "battletag",
-				Description: "Your Overwatch BattleTag (e.g. Player#1234)",
-				Required:    true,
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "battletag",
+				Description:  "Your Overwatch BattleTag (e.g. Player#1234), leave empty to fill the registration form",
+				Required:     false,
+				Autocomplete: true,
 			},
 		},
 	}
@@ -0,0 +1,260 @@
+package commands_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/borisjacquot/juno/internal/commands"
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/database/memory"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	testGuildID = "100000000000000001"
+	testUserID  = "200000000000000001"
+)
+
+// message is an answer of a command, an interaction response or an edit of it
+type message struct {
+	Content string                    `json:"content"`
+	Embeds  []*discordgo.MessageEmbed `json:"embeds"`
+}
+
+// text returns the content of the message, or the title and description of its first embed
+func (m message) text() string {
+	if m.Content != "" || len(m.Embeds) == 0 {
+		return m.Content
+	}
+	return m.Embeds[0].Title + "\n" + m.Embeds[0].Description
+}
+
+// discord answers the REST requests of a session and records the messages sent by the commands
+type discord struct {
+	mu       sync.Mutex
+	messages []message
+}
+
+func (d *discord) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent message
+	if strings.HasSuffix(req.URL.Path, "/callback") {
+		var response struct {
+			Data *message `json:"data"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, err
+		}
+		if response.Data != nil {
+			sent = *response.Data
+		}
+	} else if err := json.Unmarshal(body, &sent); err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.messages = append(d.messages, sent)
+	d.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewBufferString("{}")),
+		Request:    req,
+	}, nil
+}
+
+// last returns the text of the last message sent
+func (d *discord) last() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.messages) == 0 {
+		return ""
+	}
+	return d.messages[len(d.messages)-1].text()
+}
+
+// newSession returns a session whose requests are answered by a fake Discord
+func newSession(t *testing.T) (*discordgo.Session, *discord) {
+	t.Helper()
+
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatalf("failed to create session: %v", err)
+	}
+	d := &discord{}
+	s.Client = &http.Client{Transport: d}
+	return s, d
+}
+
+func newLogger() *log.Logger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
+}
+
+// slashInteraction returns the interaction of a slash command used by the test user in the test guild
+func slashInteraction(name string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:      "300000000000000001",
+		AppID:   "400000000000000001",
+		Token:   "token",
+		Type:    discordgo.InteractionApplicationCommand,
+		GuildID: testGuildID,
+		Member:  &discordgo.Member{User: &discordgo.User{ID: testUserID, Username: "tester"}},
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name:    name,
+			Options: options,
+		},
+	}}
+}
+
+// modalInteraction returns the submission of a form filled by the test user in the test guild
+func modalInteraction(customID string, values map[string]string) *discordgo.InteractionCreate {
+	rows := []discordgo.MessageComponent{}
+	for id, value := range values {
+		rows = append(rows, &discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			&discordgo.TextInput{CustomID: id, Value: value},
+		}})
+	}
+
+	i := slashInteraction("")
+	i.Type = discordgo.InteractionModalSubmit
+	i.Data = discordgo.ModalSubmitInteractionData{CustomID: customID, Components: rows}
+	return i
+}
+
+func stringOption(name, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Name:  name,
+		Type:  discordgo.ApplicationCommandOptionString,
+		Value: value,
+	}
+}
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name         string
+		interaction  *discordgo.InteractionCreate
+		wantMessage  string
+		wantTag      string
+		wantPlatform string
+		wantRoles    []string
+	}{
+		{
+			name:        "slash command",
+			interaction: slashInteraction("register", stringOption("battletag", "Player#1234")),
+			wantMessage: "BattleTag Registered",
+			wantTag:     "Player-1234",
+		},
+		{
+			name:        "invalid BattleTag",
+			interaction: slashInteraction("register", stringOption("battletag", "Player")),
+			wantMessage: "Invalid BattleTag format",
+		},
+		{
+			name: "form with preferences",
+			interaction: modalInteraction("register", map[string]string{
+				"battletag": "Player#1234",
+				"platform":  "Console",
+				"roles":     "dps, support, dps",
+			}),
+			wantMessage:  "BattleTag Registered",
+			wantTag:      "Player-1234",
+			wantPlatform: database.PlatformConsole,
+			wantRoles:    []string{database.RoleDamage, database.RoleSupport},
+		},
+		{
+			name: "form with an empty platform",
+			interaction: modalInteraction("register", map[string]string{
+				"battletag": "Player#1234",
+			}),
+			wantMessage: "BattleTag Registered",
+			wantTag:     "Player-1234",
+		},
+		{
+			name: "form with an unknown platform",
+			interaction: modalInteraction("register", map[string]string{
+				"battletag": "Player#1234",
+				"platform":  "mobile",
+			}),
+			wantMessage: "Invalid form",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := memory.New()
+			cmd := commands.NewRegisterCommand(store, newLogger())
+			s, discord := newSession(t)
+
+			var err error
+			if tt.interaction.Type == discordgo.InteractionModalSubmit {
+				err = cmd.HandleModal(ctx, s, tt.interaction, nil)
+			} else {
+				err = cmd.ExecuteSlash(ctx, s, tt.interaction)
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(discord.last(), tt.wantMessage) {
+				t.Errorf("message = %q, want %q", discord.last(), tt.wantMessage)
+			}
+
+			registration, err := store.GetUserRegistration(ctx, testGuildID, testUserID)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantTag == "" {
+				if registration != nil {
+					t.Errorf("registration = %+v, want none", registration)
+				}
+				return
+			}
+			if registration == nil {
+				t.Fatal("registration = nil, want one")
+			}
+			if registration.BattleTag != tt.wantTag || registration.Platform != tt.wantPlatform || !slices.Equal(registration.RoleList(), tt.wantRoles) {
+				t.Errorf("registration = %+v, want %s on %q with roles %v", registration, tt.wantTag, tt.wantPlatform, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestRegisterAfterUnregister(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	cmd := commands.NewRegisterCommand(store, newLogger())
+	s, _ := newSession(t)
+
+	if err := cmd.ExecuteSlash(ctx, s, slashInteraction("register", stringOption("battletag", "Player#1234"))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.UnregisterUser(ctx, testGuildID, testUserID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cmd.ExecuteSlash(ctx, s, slashInteraction("register", stringOption("battletag", "Other#5678"))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	battleTag, err := store.GetUserBattleTag(ctx, testGuildID, testUserID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if battleTag != "Other-5678" {
+		t.Errorf("BattleTag = %q, want Other-5678", battleTag)
+	}
+}
@@ -0,0 +1,320 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/interaction"
+	"github.com/borisjacquot/juno/internal/transfer"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxImportSize is the largest file accepted by /registrations import
+	maxImportSize = 1024 * 1024
+
+	// maxReportedErrors is the number of row errors listed in the import report
+	maxReportedErrors = 15
+
+	// maxFieldLength is the longest value Discord accepts in an embed field
+	maxFieldLength = 1024
+
+	// maxErrorLength is the longest row error listed in the import report, longer ones are cut
+	maxErrorLength = 150
+)
+
+// RegistrationsCommand lets guild admins export and import the BattleTags registered in their guild
+type RegistrationsCommand struct {
+	registrations database.RegistrationStore
+	httpClient    *http.Client
+	logger        *log.Logger
+}
+
+// NewRegistrationsCommand creates the command, imported files are downloaded with the given timeout
+func NewRegistrationsCommand(registrations database.RegistrationStore, downloadTimeout time.Duration, logger *log.Logger) *RegistrationsCommand {
+	return &RegistrationsCommand{
+		registrations: registrations,
+		httpClient: &http.Client{
+			Timeout: downloadTimeout,
+		},
+		logger: logger,
+	}
+}
+
+func (c *RegistrationsCommand) Name() string {
+	return "registrations"
+}
+
+func (c *RegistrationsCommand) Description() string {
+	return "Export or import the BattleTags registered in this server"
+}
+
+func (c *RegistrationsCommand) Category() string {
+	return "Admin"
+}
+
+func (c *RegistrationsCommand) GuildOnly() bool {
+	return true
+}
+
+func (c *RegistrationsCommand) RequiredPermissions() int64 {
+	return discordgo.PermissionManageGuild
+}
+
+func (c *RegistrationsCommand) ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	return ExecuteSubcommand(ctx, c, s, i)
+}
+
+func (c *RegistrationsCommand) Subcommands() []*Subcommand {
+	return []*Subcommand{
+		{
+			Name:        "export",
+			Description: "Download the registrations of this server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        "format",
+					Description: "File format (CSV by default)",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "CSV", Value: string(transfer.FormatCSV)},
+						{Name: "JSON", Value: string(transfer.FormatJSON)},
+					},
+				},
+			},
+			Handler: c.export,
+		},
+		{
+			Name:        "import",
+			Description: "Register every user listed in a CSV or JSON file (columns: user_id, battletag)",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionAttachment,
+					Name:        "file",
+					Description: "The CSV or JSON file to import",
+					Required:    true,
+				},
+			},
+			Handler: c.importFile,
+		},
+	}
+}
+
+func (c *RegistrationsCommand) SubcommandGroups() []*SubcommandGroup {
+	return nil
+}
+
+// export uploads the registrations of the guild as a CSV or JSON file
+func (c *RegistrationsCommand) export(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	format := transfer.FormatCSV
+	if len(options) > 0 {
+		parsed, err := transfer.ParseFormat(options[0].StringValue())
+		if err != nil {
+			return err
+		}
+		format = parsed
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	registrations, err := c.registrations.GetGuildRegistrations(ctx, i.GuildID)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to get guild registrations from database")
+		return c.editResponse(s, i, "❌ Failed to retrieve the registrations.")
+	}
+
+	var buf bytes.Buffer
+	if err := transfer.Export(&buf, format, registrations); err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to export guild registrations")
+		return c.editResponse(s, i, "❌ Failed to export the registrations.")
+	}
+
+	c.logger.WithContext(ctx).WithFields(log.Fields{
+		"user":     interaction.User(i.Interaction).Username,
+		"guild_id": i.GuildID,
+		"format":   format,
+		"count":    len(registrations),
+	}).Info("Exported guild registrations")
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: stringPtr(fmt.Sprintf("✅ Exported %d registrations", len(registrations))),
+		Files: []*discordgo.File{
+			{
+				Name:        fmt.Sprintf("registrations-%s.%s", i.GuildID, format),
+				ContentType: contentType(format),
+				Reader:      &buf,
+			},
+		},
+	})
+	return err
+}
+
+// importFile registers every valid row of the attached file and reports the invalid ones
+func (c *RegistrationsCommand) importFile(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error {
+	if len(options) == 0 {
+		return fmt.Errorf("you must attach a CSV or JSON file")
+	}
+
+	attachment, ok := i.ApplicationCommandData().Resolved.Attachments[options[0].Value.(string)]
+	if !ok {
+		return fmt.Errorf("attachment not found")
+	}
+
+	format, err := transfer.FormatFromFilename(attachment.Filename)
+	if err != nil {
+		return c.respondError(s, i, "❌ The file must be a `.csv` or `.json` file.")
+	}
+	if attachment.Size > maxImportSize {
+		return c.respondError(s, i, fmt.Sprintf("❌ The file is too large (max %d KiB).", maxImportSize/1024))
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := c.download(ctx, attachment.URL)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to download import file")
+		return c.editResponse(s, i, "❌ Failed to download the file.")
+	}
+
+	records, rowErrors, err := transfer.Parse(bytes.NewReader(data), format)
+	if err != nil {
+		return c.editResponse(s, i, fmt.Sprintf("❌ %v", err))
+	}
+
+	imported, err := transfer.Import(ctx, c.registrations, i.GuildID, records, c.logger)
+	if err != nil {
+		c.logger.WithContext(ctx).WithError(err).Error("Failed to import guild registrations")
+		return c.editResponse(s, i, "❌ Failed to import the registrations, nothing was imported. Please try again later.")
+	}
+
+	c.logger.WithContext(ctx).WithFields(log.Fields{
+		"user":     interaction.User(i.Interaction).Username,
+		"guild_id": i.GuildID,
+		"imported": imported,
+		"errors":   len(rowErrors),
+	}).Info("Imported guild registrations")
+
+	embed := &discordgo.MessageEmbed{
+		Title:       "📥 Registrations Import",
+		Description: fmt.Sprintf("Imported **%d** registrations from `%s`.", imported, attachment.Filename),
+		Color:       0xD183C9,
+	}
+
+	if len(rowErrors) > 0 {
+		embed.Color = 0xF99E1A
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  fmt.Sprintf("⚠️ %d rows skipped", len(rowErrors)),
+			Value: errorReport(rowErrors),
+		})
+	}
+
+	_, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+	return err
+}
+
+// errorReport lists the first row errors, it always fits in an embed field
+func errorReport(rowErrors []transfer.RowError) string {
+	var report strings.Builder
+	for n, rowError := range rowErrors {
+		line := fmt.Sprintf("• %s\n", truncate(rowError.Error(), maxErrorLength))
+		// keep room for the line counting the errors left out
+		if n == maxReportedErrors || report.Len()+len(line) > maxFieldLength-50 {
+			report.WriteString(fmt.Sprintf("… and %d more\n", len(rowErrors)-n))
+			break
+		}
+		report.WriteString(line)
+	}
+	return report.String()
+}
+
+// truncate cuts s to at most limit characters, ending it with an ellipsis when it's cut
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// download retrieves the content of an attachment
+func (c *RegistrationsCommand) download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-200 response: %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxImportSize))
+}
+
+// contentType returns the MIME type of an export format
+func contentType(format transfer.Format) string {
+	if format == transfer.FormatJSON {
+		return "application/json"
+	}
+	return "text/csv"
+}
+
+func (c *RegistrationsCommand) respondError(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	return s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+func (c *RegistrationsCommand) editResponse(s *discordgo.Session, i *discordgo.InteractionCreate, message string) error {
+	_, err := s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Content: stringPtr(message),
+	})
+	return err
+}
+
+func (c *RegistrationsCommand) ToApplicationCommand() *discordgo.ApplicationCommand {
+	permissions := int64(discordgo.PermissionManageGuild)
+	dmPermission := false
+
+	return &discordgo.ApplicationCommand{
+		Name:                     c.Name(),
+		Description:              c.Description(),
+		DefaultMemberPermissions: &permissions,
+		DMPermission:             &dmPermission,
+		Options:                  SubcommandOptions(c),
+	}
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
@@ -21,23 +22,245 @@ type Command interface {
 	Category() string
 
 	// ExecuteSlash executes the command as a slash command with the given arguments and context
-	ExecuteSlash(s *discordgo.Session, i *discordgo.InteractionCreate) error
+	ExecuteSlash(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) error
 
-	// ToApplicationCommand converts the command to a Discord application command (for slash commands)
+	// ToApplicationCommand converts the command to a Discord application command (slash or context menu command)
 	ToApplicationCommand() *discordgo.ApplicationCommand
 }
 
+// ContextMenuCommand is implemented by user and message context menu commands (right click > Apps)
+// Their name is shown as is in the menu, they have no options and are executed through ExecuteSlash
+type ContextMenuCommand interface {
+	Command
+
+	// CommandType returns discordgo.UserApplicationCommand or discordgo.MessageApplicationCommand
+	CommandType() discordgo.ApplicationCommandType
+}
+
+// TargetUser returns the user targeted by a user context menu command
+func TargetUser(i *discordgo.InteractionCreate) (*discordgo.User, bool) {
+	data := i.ApplicationCommandData()
+	if data.Resolved == nil {
+		return nil, false
+	}
+	user, ok := data.Resolved.Users[data.TargetID]
+	return user, ok
+}
+
+// TargetMessage returns the message targeted by a message context menu command
+func TargetMessage(i *discordgo.InteractionCreate) (*discordgo.Message, bool) {
+	data := i.ApplicationCommandData()
+	if data.Resolved == nil {
+		return nil, false
+	}
+	message, ok := data.Resolved.Messages[data.TargetID]
+	return message, ok
+}
+
+// commandLabel returns how a command is invoked, "/name" for slash commands
+func commandLabel(cmd Command) string {
+	if menu, ok := cmd.(ContextMenuCommand); ok {
+		switch menu.CommandType() {
+		case discordgo.UserApplicationCommand:
+			return fmt.Sprintf("%s *(right click a user › Apps)*", cmd.Name())
+		case discordgo.MessageApplicationCommand:
+			return fmt.Sprintf("%s *(right click a message › Apps)*", cmd.Name())
+		}
+	}
+	return "/" + cmd.Name()
+}
+
+// Autocompleter is implemented by commands suggesting values for their options while the user is typing
+// Options must be declared with Autocomplete set to true in ToApplicationCommand
+type Autocompleter interface {
+	// Autocomplete returns the suggestions for the focused option of the interaction (25 at most are shown)
+	Autocomplete(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate) ([]*discordgo.ApplicationCommandOptionChoice, error)
+}
+
+// FocusedOption returns the option being typed in an autocomplete interaction, looking into subcommands
+func FocusedOption(options []*discordgo.ApplicationCommandInteractionDataOption) *discordgo.ApplicationCommandInteractionDataOption {
+	for _, option := range options {
+		if option.Focused {
+			return option
+		}
+		if focused := FocusedOption(option.Options); focused != nil {
+			return focused
+		}
+	}
+	return nil
+}
+
+// commandChoices suggests the names of the commands of the registry matching the focused option
+func commandChoices(registry *Registry, i *discordgo.InteractionCreate) []*discordgo.ApplicationCommandOptionChoice {
+	query := ""
+	if focused := FocusedOption(i.ApplicationCommandData().Options); focused != nil {
+		query = strings.ToLower(strings.TrimPrefix(focused.StringValue(), "/"))
+	}
+
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, cmd := range registry.All() {
+		if strings.Contains(strings.ToLower(cmd.Name()), query) {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+				Name:  commandLabel(cmd),
+				Value: cmd.Name(),
+			})
+		}
+	}
+	return choices
+}
+
+// SubcommandHandler executes a subcommand with the options given to it
+type SubcommandHandler func(ctx context.Context, s *discordgo.Session, i *discordgo.InteractionCreate, options []*discordgo.ApplicationCommandInteractionDataOption) error
+
+// Subcommand is a subcommand of a command (e.g. "export" in "/registrations export")
+type Subcommand struct {
+	Name        string
+	Description string
+	Options     []*discordgo.ApplicationCommandOption
+	Handler     SubcommandHandler
+}
+
+// SubcommandGroup is a named group of subcommands (e.g. "set" in "/config set platform")
+type SubcommandGroup struct {
+	Name        string
+	Description string
+	Subcommands []*Subcommand
+}
+
+// GroupCommand is implemented by commands made of subcommands and subcommand groups
+// The handler routes their interactions to the handler of the invoked subcommand
+type GroupCommand interface {
+	Command
+
+	// Subcommands returns the subcommands at the root of the command
+	Subcommands() []*Subcommand
+
+	// SubcommandGroups returns the groups of subcommands of the command
+	SubcommandGroups() []*SubcommandGroup
+}
+
+// SubcommandOptions builds the application command options declaring the subcommands of a command
+func SubcommandOptions(cmd GroupCommand) []*discordgo.ApplicationCommandOption {
+	options := []*discordgo.ApplicationCommandOption{}
+
+	for _, group := range cmd.SubcommandGroups() {
+		groupOption := &discordgo.ApplicationCommandOption{
+			Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+			Name:        group.Name,
+			Description: group.Description,
+		}
+		for _, sub := range group.Subcommands {
+			groupOption.Options = append(groupOption.Options, subcommandOption(sub))
+		}
+		options = append(options, groupOption)
+	}
+
+	for _, sub := range cmd.Subcommands() {
+		options = append(options, subcommandOption(sub))
+	}
+
+	return options
+}
+
+func subcommandOption(sub *Subcommand) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionSubCommand,
+		Name:        sub.Name,
+		Description: sub.Description,
+		Options:     sub.Options,
+	}
+}
+
+// ResolveSubcommand finds the subcommand invoked by an interaction
+// It returns the subcommand, its path (e.g. "config set platform") and the options given to it
+func ResolveSubcommand(cmd GroupCommand, options []*discordgo.ApplicationCommandInteractionDataOption) (*Subcommand, string, []*discordgo.ApplicationCommandInteractionDataOption, error) {
+	if len(options) == 0 {
+		return nil, "", nil, fmt.Errorf("missing subcommand for command '%s'", cmd.Name())
+	}
+
+	option := options[0]
+	switch option.Type {
+	case discordgo.ApplicationCommandOptionSubCommandGroup:
+		for _, group := range cmd.SubcommandGroups() {
+			if group.Name != option.Name {
+				continue
+			}
+			if len(option.Options) == 0 {
+				return nil, "", nil, fmt.Errorf("missing subcommand for group '%s %s'", cmd.Name(), group.Name)
+			}
+			for _, sub := range group.Subcommands {
+				if sub.Name == option.Options[0].Name {
+					return sub, fmt.Sprintf("%s %s %s", cmd.Name(), group.Name, sub.Name), option.Options[0].Options, nil
+				}
+			}
+		}
+	case discordgo.ApplicationCommandOptionSubCommand:
+		for _, sub := range cmd.Subcommands() {
+			if sub.Name == option.Name {
+				return sub, fmt.Sprintf("%s %s", cmd.Name(), sub.Name), option.Options, nil
+			}
+		}
+	}
+
+	return nil, "", nil, fmt.Errorf("unknown subcommand '%s' for command '%s'", option.Name, cmd.Name())
+}
+
+// ExecuteSubcommand runs the subcommand invoked by an interaction, group commands use it as their ExecuteSlash
+func ExecuteSubcommand(ctx context.Context, cmd GroupCommand, s *discordgo.Session, i *discordgo.InteractionCreate) error {
+	sub, _, options, err := ResolveSubcommand(cmd, i.ApplicationCommandData().Options)
+	if err != nil {
+		return err
+	}
+	return sub.Handler(ctx, s, i, options)
+}
+
+// validateGroupCommand makes sure every subcommand of a command has a unique name and a handler
+func validateGroupCommand(cmd GroupCommand) error {
+	names := make(map[string]bool)
+
+	check := func(prefix string, subs []*Subcommand) error {
+		for _, sub := range subs {
+			path := strings.TrimSpace(prefix + " " + sub.Name)
+			if names[path] {
+				return fmt.Errorf("subcommand '%s %s' is declared twice", cmd.Name(), path)
+			}
+			if sub.Handler == nil {
+				return fmt.Errorf("subcommand '%s %s' has no handler", cmd.Name(), path)
+			}
+			names[path] = true
+		}
+		return nil
+	}
+
+	for _, group := range cmd.SubcommandGroups() {
+		if names[group.Name] {
+			return fmt.Errorf("subcommand group '%s %s' is declared twice", cmd.Name(), group.Name)
+		}
+		names[group.Name] = true
+
+		if err := check(group.Name, group.Subcommands); err != nil {
+			return err
+		}
+	}
+
+	return check("", cmd.Subcommands())
+}
+
 // Registry is a registry of commands that can be executed by the bot
 type Registry struct {
-	commands map[string]Command
-	logger   *log.Logger
+	commands   map[string]Command
+	components map[string]Command
+	modals     map[string]Command
+	logger     *log.Logger
 }
 
 // NewRegistry creates a new command registry
 func NewRegistry(logger *log.Logger) *Registry {
 	return &Registry{
-		commands: make(map[string]Command),
-		logger:   logger,
+		commands:   make(map[string]Command),
+		components: make(map[string]Command),
+		modals:     make(map[string]Command),
+		logger:     logger,
 	}
 }
 
@@ -49,6 +272,51 @@ func (r *Registry) Register(cmd Command) error {
 		return fmt.Errorf("command with name '%s' already exists", name)
 	}
 
+	if group, ok := cmd.(GroupCommand); ok {
+		if err := validateGroupCommand(group); err != nil {
+			return err
+		}
+	}
+
+	// guild only commands must be hidden from direct messages
+	if guildOnly, ok := cmd.(GuildOnlyCommand); ok && guildOnly.GuildOnly() {
+		if dmPermission := cmd.ToApplicationCommand().DMPermission; dmPermission == nil || *dmPermission {
+			return fmt.Errorf("guild only command '%s' must set DMPermission to false", name)
+		}
+	}
+
+	// and commands requiring permissions must hide themselves from the members missing them
+	if permissioned, ok := cmd.(PermissionedCommand); ok && permissioned.RequiredPermissions() != 0 {
+		required := permissioned.RequiredPermissions()
+		if defaults := cmd.ToApplicationCommand().DefaultMemberPermissions; defaults == nil || *defaults&required != required {
+			return fmt.Errorf("command '%s' requires permissions it doesn't declare in DefaultMemberPermissions", name)
+		}
+	}
+
+	// commands handling components are also routed by the prefix of their custom IDs
+	if handler, ok := cmd.(ComponentHandler); ok {
+		prefix := handler.ComponentPrefix()
+		if prefix == "" || strings.Contains(prefix, customIDSeparator) {
+			return fmt.Errorf("invalid component prefix '%s' for command '%s'", prefix, name)
+		}
+		if _, exists := r.components[prefix]; exists {
+			return fmt.Errorf("component prefix '%s' is already used", prefix)
+		}
+		r.components[prefix] = cmd
+	}
+
+	// and commands opening modals by the prefix of the modal custom IDs
+	if handler, ok := cmd.(ModalHandler); ok {
+		prefix := handler.ModalPrefix()
+		if prefix == "" || strings.Contains(prefix, customIDSeparator) {
+			return fmt.Errorf("invalid modal prefix '%s' for command '%s'", prefix, name)
+		}
+		if _, exists := r.modals[prefix]; exists {
+			return fmt.Errorf("modal prefix '%s' is already used", prefix)
+		}
+		r.modals[prefix] = cmd
+	}
+
 	r.commands[name] = cmd
 	r.logger.WithFields(log.Fields{
 		"name":     cmd.Name(),
@@ -71,6 +339,18 @@ func (r *Registry) Get(name string) (Command, bool) {
 	return cmd, exists
 }
 
+// Component retrieves the command handling the components with the given custom ID prefix, it implements ComponentHandler
+func (r *Registry) Component(prefix string) (Command, bool) {
+	cmd, exists := r.components[prefix]
+	return cmd, exists
+}
+
+// Modal retrieves the command handling the modals with the given custom ID prefix, it implements ModalHandler
+func (r *Registry) Modal(prefix string) (Command, bool) {
+	cmd, exists := r.modals[prefix]
+	return cmd, exists
+}
+
 // All returns a slice of all registered commands
 func (r *Registry) All() []Command {
 	commands := make([]Command, 0, len(r.commands))
@@ -0,0 +1,393 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// commandScopes tells where each command must be registered
+// Commands without toggles are registered globally, toggled commands are registered in every guild where they are
+// enabled since global commands can't be hidden in a single guild
+type commandScopes struct {
+	toggled  map[string]bool            // commands with at least one toggle
+	defaults map[string]bool            // default of every guild, by command
+	guilds   map[string]map[string]bool // toggles by guild, then by command
+}
+
+// loadScopes reads the command toggles, they aren't cached since replicas sharing the database can change them
+func (h *Handler) loadScopes() (*commandScopes, error) {
+	toggles, err := h.features.GetCommandToggles(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := &commandScopes{
+		toggled:  make(map[string]bool),
+		defaults: make(map[string]bool),
+		guilds:   make(map[string]map[string]bool),
+	}
+	for _, toggle := range toggles {
+		scopes.toggled[toggle.Command] = true
+		if toggle.GuildID == database.GlobalGuildID {
+			scopes.defaults[toggle.Command] = toggle.Enabled
+			continue
+		}
+		if scopes.guilds[toggle.GuildID] == nil {
+			scopes.guilds[toggle.GuildID] = make(map[string]bool)
+		}
+		scopes.guilds[toggle.GuildID][toggle.Command] = toggle.Enabled
+	}
+
+	return scopes, nil
+}
+
+// enabled reports whether a command is enabled in a guild, the toggle of the guild wins over the default
+func (c *commandScopes) enabled(guildID, command string) bool {
+	if enabled, ok := c.guilds[guildID][command]; ok {
+		return enabled
+	}
+	if enabled, ok := c.defaults[command]; ok {
+		return enabled
+	}
+	return true
+}
+
+// toggledElsewhere reports whether a command has a toggle in another guild than guildID, or a default when guildID
+// isn't the global one
+func (c *commandScopes) toggledElsewhere(command, guildID string) bool {
+	if _, ok := c.defaults[command]; ok && guildID != database.GlobalGuildID {
+		return true
+	}
+	for id, toggles := range c.guilds {
+		if _, ok := toggles[command]; ok && id != guildID {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplicationCommands returns the definitions of every registered command, sorted like the registry
+func (h *Handler) ApplicationCommands() []*discordgo.ApplicationCommand {
+	definitions := []*discordgo.ApplicationCommand{}
+	for _, cmd := range h.registry.All() {
+		definitions = append(definitions, cmd.ToApplicationCommand())
+	}
+	return definitions
+}
+
+// SyncCommands makes the global application commands registered on Discord match the registry
+// The registered commands are fetched and compared with the registry, they are only replaced, with a single bulk
+// overwrite, when a definition changed, so reconnecting and restarting the bot don't touch them
+// When development guilds are configured the global commands are left untouched, see SyncGuildCommands
+func (h *Handler) SyncCommands(s *discordgo.Session) error {
+	if len(h.devGuildIDs) > 0 {
+		h.logger.WithField("guilds", h.devGuildIDs).Info("Development guilds are configured, skipping global slash commands")
+		return nil
+	}
+
+	scopes, err := h.loadScopes()
+	if err != nil {
+		return fmt.Errorf("failed to load command toggles: %w", err)
+	}
+
+	return h.syncGlobal(s, scopes)
+}
+
+// SyncGuildCommands makes the commands registered in a guild match the registry
+// They are the toggled commands enabled in the guild, or every enabled command in development guilds which
+// get them instantly instead of waiting for the global commands to propagate
+// The commands registered in a guild stay there, a guild that becomes available is only synchronized when it's a
+// development guild, when it has toggles of its own or when the bot just joined it and it gets commands, so the guild
+// create events received at startup don't exhaust the rate limits
+func (h *Handler) SyncGuildCommands(s *discordgo.Session, guildID string, joined bool) error {
+	scopes, err := h.loadScopes()
+	if err != nil {
+		return fmt.Errorf("failed to load command toggles: %w", err)
+	}
+
+	dev := slices.Contains(h.devGuildIDs, guildID)
+	commands := h.guildCommands(scopes, guildID)
+	if !dev && scopes.guilds[guildID] == nil && !(joined && len(commands) > 0) {
+		return nil
+	}
+
+	_, err = h.syncScope(s, guildID, definitionsOf(commands))
+	return err
+}
+
+// SyncAllCommands synchronizes the global commands and the commands of every guild with commands of its own
+func (h *Handler) SyncAllCommands(s *discordgo.Session) error {
+	scopes, err := h.loadScopes()
+	if err != nil {
+		return fmt.Errorf("failed to load command toggles: %w", err)
+	}
+
+	if err := h.syncGlobal(s, scopes); err != nil {
+		return err
+	}
+
+	return h.syncGuilds(s, scopes, h.guildIDs(s), true)
+}
+
+// SyncToggledCommand synchronizes the commands after the toggle of command in guildID changed
+// Only that guild can have changed, unless the toggle is the global default or it's the first or last toggle of the
+// command, which moves the command between the global scope and the guilds, then every guild is synchronized
+func (h *Handler) SyncToggledCommand(s *discordgo.Session, guildID, command string) error {
+	scopes, err := h.loadScopes()
+	if err != nil {
+		return fmt.Errorf("failed to load command toggles: %w", err)
+	}
+
+	if err := h.syncGlobal(s, scopes); err != nil {
+		return err
+	}
+
+	if guildID != database.GlobalGuildID && scopes.toggledElsewhere(command, guildID) {
+		return h.syncGuilds(s, scopes, []string{guildID}, false)
+	}
+	return h.syncGuilds(s, scopes, h.guildIDs(s), false)
+}
+
+// syncGuilds synchronizes the commands of guilds, skipping the guilds without commands of their own when skipEmpty is set
+// A guild failing to synchronize is logged and doesn't stop the others
+func (h *Handler) syncGuilds(s *discordgo.Session, scopes *commandScopes, guildIDs []string, skipEmpty bool) error {
+	synced := 0
+	for _, guildID := range guildIDs {
+		commands := h.guildCommands(scopes, guildID)
+		if skipEmpty && len(commands) == 0 {
+			continue
+		}
+
+		synced++
+		if _, err := h.syncScope(s, guildID, definitionsOf(commands)); err != nil {
+			h.logger.WithError(err).WithField("guild_id", guildID).Error("Failed to synchronize guild slash commands")
+		}
+	}
+
+	h.logger.WithField("guilds", synced).Debug("Synchronized guild slash commands")
+	return nil
+}
+
+// syncGlobal synchronizes the global commands, every command without toggles
+// When development guilds are configured the global commands are left untouched
+func (h *Handler) syncGlobal(s *discordgo.Session, scopes *commandScopes) error {
+	if len(h.devGuildIDs) > 0 {
+		return nil
+	}
+
+	commands := []Command{}
+	for _, cmd := range h.registry.All() {
+		if !scopes.toggled[strings.ToLower(cmd.Name())] {
+			commands = append(commands, cmd)
+		}
+	}
+
+	_, err := h.syncScope(s, "", definitionsOf(commands))
+	return err
+}
+
+// guildCommands returns the commands registered in a guild, sorted like the registry
+func (h *Handler) guildCommands(scopes *commandScopes, guildID string) []Command {
+	dev := slices.Contains(h.devGuildIDs, guildID)
+	if len(h.devGuildIDs) > 0 && !dev {
+		return nil
+	}
+
+	commands := []Command{}
+	for _, cmd := range h.registry.All() {
+		name := strings.ToLower(cmd.Name())
+		if (dev || scopes.toggled[name]) && scopes.enabled(guildID, name) {
+			commands = append(commands, cmd)
+		}
+	}
+	return commands
+}
+
+// definitionsOf returns the definitions of commands
+func definitionsOf(commands []Command) []*discordgo.ApplicationCommand {
+	definitions := make([]*discordgo.ApplicationCommand, 0, len(commands))
+	for _, cmd := range commands {
+		definitions = append(definitions, cmd.ToApplicationCommand())
+	}
+	return definitions
+}
+
+// SetGuildSource sets the function listing the guilds the bot is in, used when every guild is synchronized
+// By default they are read from the state of the session, which only holds the guilds of its shard
+func (h *Handler) SetGuildSource(guilds func() []string) {
+	h.guilds = guilds
+}
+
+// guildIDs returns the IDs of the guilds the bot is in
+func (h *Handler) guildIDs(s *discordgo.Session) []string {
+	if h.guilds != nil {
+		return h.guilds()
+	}
+
+	s.State.RLock()
+	defer s.State.RUnlock()
+	guildIDs := make([]string, 0, len(s.State.Guilds))
+	for _, guild := range s.State.Guilds {
+		guildIDs = append(guildIDs, guild.ID)
+	}
+	return guildIDs
+}
+
+// syncScope synchronizes the commands of a guild, or the global commands if guildID is empty
+// It returns whether the commands were overwritten
+func (h *Handler) syncScope(s *discordgo.Session, guildID string, definitions []*discordgo.ApplicationCommand) (bool, error) {
+	appID := s.State.User.ID
+	entry := h.logger.WithField("guild_id", guildID)
+
+	registered, err := s.ApplicationCommands(appID, guildID)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch registered commands: %w", err)
+	}
+
+	added, changed, removed := diffCommands(registered, definitions)
+	if len(added)+len(changed)+len(removed) == 0 {
+		entry.WithField("count", len(definitions)).Debug("Slash commands are up to date")
+		return false, nil
+	}
+
+	entry.WithFields(log.Fields{
+		"added":   added,
+		"changed": changed,
+		"removed": removed,
+	}).Info("Slash commands changed, overwriting them")
+
+	if _, err := s.ApplicationCommandBulkOverwrite(appID, guildID, definitions); err != nil {
+		return false, fmt.Errorf("failed to overwrite commands: %w", err)
+	}
+
+	entry.WithField("count", len(definitions)).Info("Synchronized slash commands")
+	return true, nil
+}
+
+// diffCommands compares the commands registered on Discord with their definitions
+// Commands are identified by their type and name, it returns the names of the added, changed and removed commands
+func diffCommands(registered, definitions []*discordgo.ApplicationCommand) (added, changed, removed []string) {
+	current := make(map[string]string, len(registered))
+	for _, cmd := range registered {
+		current[commandKey(cmd)] = commandSignature(cmd)
+	}
+
+	wanted := make(map[string]bool, len(definitions))
+	for _, cmd := range definitions {
+		key := commandKey(cmd)
+		wanted[key] = true
+
+		signature, exists := current[key]
+		switch {
+		case !exists:
+			added = append(added, cmd.Name)
+		case signature != commandSignature(cmd):
+			changed = append(changed, cmd.Name)
+		}
+	}
+
+	for _, cmd := range registered {
+		if !wanted[commandKey(cmd)] {
+			removed = append(removed, cmd.Name)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(changed)
+	sort.Strings(removed)
+	return added, changed, removed
+}
+
+// commandKey identifies a command, a slash command and a context menu command can share a name
+func commandKey(cmd *discordgo.ApplicationCommand) string {
+	return fmt.Sprintf("%d/%s", commandType(cmd), cmd.Name)
+}
+
+// commandType returns the type of a command, Discord defaults it to slash commands
+func commandType(cmd *discordgo.ApplicationCommand) discordgo.ApplicationCommandType {
+	if cmd.Type == 0 {
+		return discordgo.ChatApplicationCommand
+	}
+	return cmd.Type
+}
+
+// commandDefinition holds the fields of a command compared by the synchronization
+// The defaults applied by Discord are made explicit so definitions compare equal to the commands Discord returns
+type commandDefinition struct {
+	Type                     discordgo.ApplicationCommandType `json:"type"`
+	Name                     string                           `json:"name"`
+	Description              string                           `json:"description"`
+	DefaultMemberPermissions *int64                           `json:"default_member_permissions"`
+	DMPermission             bool                             `json:"dm_permission"`
+	NSFW                     bool                             `json:"nsfw"`
+	Options                  []optionDefinition               `json:"options"`
+}
+
+type optionDefinition struct {
+	Type         discordgo.ApplicationCommandOptionType `json:"type"`
+	Name         string                                 `json:"name"`
+	Description  string                                 `json:"description"`
+	Required     bool                                   `json:"required"`
+	Autocomplete bool                                   `json:"autocomplete"`
+	Choices      []string                               `json:"choices"`
+	ChannelTypes []discordgo.ChannelType                `json:"channel_types"`
+	MinValue     *float64                               `json:"min_value"`
+	MaxValue     float64                                `json:"max_value"`
+	MinLength    *int                                   `json:"min_length"`
+	MaxLength    int                                    `json:"max_length"`
+	Options      []optionDefinition                     `json:"options"`
+}
+
+// commandSignature returns a representation of a command that only changes when its definition does
+func commandSignature(cmd *discordgo.ApplicationCommand) string {
+	definition := commandDefinition{
+		Type:                     commandType(cmd),
+		Name:                     cmd.Name,
+		Description:              cmd.Description,
+		DefaultMemberPermissions: cmd.DefaultMemberPermissions,
+		DMPermission:             cmd.DMPermission == nil || *cmd.DMPermission,
+		NSFW:                     cmd.NSFW != nil && *cmd.NSFW,
+		Options:                  optionDefinitions(cmd.Options),
+	}
+
+	signature, err := json.Marshal(definition)
+	if err != nil {
+		// can't happen with these types, an empty signature forces an overwrite
+		return ""
+	}
+	return string(signature)
+}
+
+func optionDefinitions(options []*discordgo.ApplicationCommandOption) []optionDefinition {
+	definitions := make([]optionDefinition, 0, len(options))
+	for _, option := range options {
+		choices := make([]string, 0, len(option.Choices))
+		for _, choice := range option.Choices {
+			choices = append(choices, fmt.Sprintf("%s=%v", choice.Name, choice.Value))
+		}
+
+		definitions = append(definitions, optionDefinition{
+			Type:         option.Type,
+			Name:         option.Name,
+			Description:  option.Description,
+			Required:     option.Required,
+			Autocomplete: option.Autocomplete,
+			Choices:      choices,
+			ChannelTypes: option.ChannelTypes,
+			MinValue:     option.MinValue,
+			MaxValue:     option.MaxValue,
+			MinLength:    option.MinLength,
+			MaxLength:    option.MaxLength,
+			Options:      optionDefinitions(option.Options),
+		})
+	}
+	return definitions
+}
@@ -0,0 +1,391 @@
+// Package config loads the configuration of the bot from an optional YAML or TOML file and the environment
+// Environment variables override the values of the file, which override the defaults
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/borisjacquot/juno/internal/presence"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the bot
+type Config struct {
+	Discord   Discord   `yaml:"discord" toml:"discord"`
+	Overwatch Overwatch `yaml:"overwatch" toml:"overwatch"`
+	Database  Database  `yaml:"database" toml:"database"`
+	Backup    Backup    `yaml:"backup" toml:"backup"`
+	Log       Log       `yaml:"log" toml:"log"`
+	HTTP      HTTP      `yaml:"http" toml:"http"`
+	Shutdown  Shutdown  `yaml:"shutdown" toml:"shutdown"`
+	Features  Features  `yaml:"features" toml:"features"`
+	Presence  Presence  `yaml:"presence" toml:"presence"`
+}
+
+// Discord configures the connection to Discord
+type Discord struct {
+	Token           string        `yaml:"token" toml:"token"`                       // DISCORD_TOKEN
+	OwnerID         string        `yaml:"owner_id" toml:"owner_id"`                 // OWNER_ID, allowed to use /admin
+	ErrorChannelID  string        `yaml:"error_channel_id" toml:"error_channel_id"` // ERROR_CHANNEL_ID, where incidents are posted
+	DevGuildIDs     []string      `yaml:"dev_guild_ids" toml:"dev_guild_ids"`       // DEV_GUILD_IDS, comma separated in the environment
+	DownloadTimeout time.Duration `yaml:"download_timeout" toml:"download_timeout"` // DOWNLOAD_TIMEOUT, for the files attached to commands
+	ShardCount      int           `yaml:"shard_count" toml:"shard_count"`           // SHARD_COUNT, 0 uses the count recommended by Discord
+}
+
+// Overwatch configures the client of the OverFast API
+type Overwatch struct {
+	APIURL      string        `yaml:"api_url" toml:"api_url"`             // OVERFAST_API_URL
+	Timeout     time.Duration `yaml:"timeout" toml:"timeout"`             // OVERFAST_TIMEOUT
+	GameDataTTL time.Duration `yaml:"game_data_ttl" toml:"game_data_ttl"` // GAME_DATA_TTL, how long heroes and maps are cached
+}
+
+// Database configures the storage of the bot
+type Database struct {
+	// DSN is a SQLite path or a PostgreSQL URL, DATABASE_URL takes precedence over DATABASE_PATH
+	DSN string `yaml:"dsn" toml:"dsn"`
+	// AutoMigrate migrates the schema when the bot and the CLI commands open the database (DATABASE_AUTO_MIGRATE)
+	// Replicas sharing a database can turn it off and run the migrate command before starting
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate"`
+	// RemovedGuildGracePeriod is how long the registrations of a guild are kept after the bot was removed from it,
+	// they are kept forever when zero (REMOVED_GUILD_GRACE_PERIOD)
+	RemovedGuildGracePeriod time.Duration `yaml:"removed_guild_grace_period" toml:"removed_guild_grace_period"`
+}
+
+// Backup configures the scheduled SQLite backups
+type Backup struct {
+	Dir      string        `yaml:"dir" toml:"dir"`           // BACKUP_DIR
+	Interval time.Duration `yaml:"interval" toml:"interval"` // BACKUP_INTERVAL, scheduled backups are disabled when zero
+	Keep     int           `yaml:"keep" toml:"keep"`         // BACKUP_KEEP
+}
+
+// Log configures the logs
+type Log struct {
+	Level  string `yaml:"level" toml:"level"`   // LOG_LEVEL: debug, info, warn or error
+	Format string `yaml:"format" toml:"format"` // LOG_FORMAT: text or json
+	Redact bool   `yaml:"redact" toml:"redact"` // LOG_REDACT, replaces BattleTags and user IDs by hashes
+	// RedactKey is the secret the hashes are keyed with (LOG_REDACT_KEY), a random one is used for each run when empty
+	RedactKey string `yaml:"redact_key" toml:"redact_key"`
+}
+
+// HTTP configures the server exposing /healthz, /readyz and /metrics
+type HTTP struct {
+	Addr string `yaml:"addr" toml:"addr"` // HTTP_ADDR (e.g. :9090), the server is disabled when empty
+}
+
+// Shutdown configures how the bot stops
+type Shutdown struct {
+	// Timeout is how long the interactions and background jobs in progress are waited for, SHUTDOWN_TIMEOUT
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+// Presence configures the status of the bot
+type Presence struct {
+	// Templates are shown in turn, {guilds}, {players} and {season} are replaced by live values
+	// PRESENCE_TEMPLATES, separated by semicolons in the environment
+	Templates []string      `yaml:"templates" toml:"templates"`
+	Interval  time.Duration `yaml:"interval" toml:"interval"` // PRESENCE_INTERVAL, how long each template is shown
+}
+
+// Features turns optional features on or off
+type Features struct {
+	ContextMenus         bool `yaml:"context_menus" toml:"context_menus"`                 // FEATURE_CONTEXT_MENUS, the "Overwatch profile" user command
+	RegistrationTransfer bool `yaml:"registration_transfer" toml:"registration_transfer"` // FEATURE_REGISTRATION_TRANSFER, /registrations
+	Cooldowns            bool `yaml:"cooldowns" toml:"cooldowns"`                         // FEATURE_COOLDOWNS
+}
+
+// Default returns the configuration used when nothing is set
+func Default() *Config {
+	return &Config{
+		Discord: Discord{
+			DownloadTimeout: 10 * time.Second,
+		},
+		Overwatch: Overwatch{
+			APIURL:      "https://overfast-api.tekrop.fr",
+			Timeout:     10 * time.Second,
+			GameDataTTL: time.Hour,
+		},
+		Database: Database{
+			DSN:                     "data/overwatch-bot.db",
+			AutoMigrate:             true,
+			RemovedGuildGracePeriod: 7 * 24 * time.Hour,
+		},
+		Backup: Backup{
+			Dir:  "backups",
+			Keep: 7,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+		Shutdown: Shutdown{
+			Timeout: 20 * time.Second,
+		},
+		Features: Features{
+			ContextMenus:         true,
+			RegistrationTransfer: true,
+			Cooldowns:            true,
+		},
+		Presence: Presence{
+			Templates: []string{
+				"Overwatch | /help",
+				"{guilds} servers | /help",
+				"{players} registered players",
+				"Season {season} | /profile",
+			},
+			Interval: 5 * time.Minute,
+		},
+	}
+}
+
+// Load reads the configuration file at path, if not empty, then applies the environment variables and validates the result
+// The format of the file is chosen from its extension: .yaml, .yml or .toml
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile decodes a YAML or TOML file over the configuration
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	case ".toml":
+		metadata, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("invalid config file %s: unknown key %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension '%s', use .yaml, .yml or .toml", ext)
+	}
+
+	return nil
+}
+
+// loadEnv applies the environment variables over the configuration
+func (c *Config) loadEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+
+	str := func(name string, target *string) {
+		if value, ok := lookup(name); ok && value != "" {
+			*target = value
+		}
+	}
+	duration := func(name string, target *time.Duration) {
+		if value, ok := lookup(name); ok && value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be a duration (e.g. 30s, 24h), got '%s'", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	integer := func(name string, target *int) {
+		if value, ok := lookup(name); ok && value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be an integer, got '%s'", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+	boolean := func(name string, target *bool) {
+		if value, ok := lookup(name); ok && value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s must be true or false, got '%s'", name, value))
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	str("DISCORD_TOKEN", &c.Discord.Token)
+	str("OWNER_ID", &c.Discord.OwnerID)
+	str("ERROR_CHANNEL_ID", &c.Discord.ErrorChannelID)
+	if value, ok := lookup("DEV_GUILD_IDS"); ok && value != "" {
+		c.Discord.DevGuildIDs = splitList(value, ",")
+	}
+	duration("DOWNLOAD_TIMEOUT", &c.Discord.DownloadTimeout)
+	integer("SHARD_COUNT", &c.Discord.ShardCount)
+
+	str("OVERFAST_API_URL", &c.Overwatch.APIURL)
+	duration("OVERFAST_TIMEOUT", &c.Overwatch.Timeout)
+	duration("GAME_DATA_TTL", &c.Overwatch.GameDataTTL)
+
+	// DATABASE_URL takes precedence over DATABASE_PATH so several replicas can share a PostgreSQL database
+	str("DATABASE_PATH", &c.Database.DSN)
+	str("DATABASE_URL", &c.Database.DSN)
+	boolean("DATABASE_AUTO_MIGRATE", &c.Database.AutoMigrate)
+	duration("REMOVED_GUILD_GRACE_PERIOD", &c.Database.RemovedGuildGracePeriod)
+
+	str("BACKUP_DIR", &c.Backup.Dir)
+	duration("BACKUP_INTERVAL", &c.Backup.Interval)
+	integer("BACKUP_KEEP", &c.Backup.Keep)
+
+	str("LOG_LEVEL", &c.Log.Level)
+	str("LOG_FORMAT", &c.Log.Format)
+	boolean("LOG_REDACT", &c.Log.Redact)
+	str("LOG_REDACT_KEY", &c.Log.RedactKey)
+
+	str("HTTP_ADDR", &c.HTTP.Addr)
+
+	duration("SHUTDOWN_TIMEOUT", &c.Shutdown.Timeout)
+
+	boolean("FEATURE_CONTEXT_MENUS", &c.Features.ContextMenus)
+	boolean("FEATURE_REGISTRATION_TRANSFER", &c.Features.RegistrationTransfer)
+	boolean("FEATURE_COOLDOWNS", &c.Features.Cooldowns)
+
+	// the templates can contain commas, they are separated by semicolons
+	if value, ok := lookup("PRESENCE_TEMPLATES"); ok && value != "" {
+		c.Presence.Templates = splitList(value, ";")
+	}
+	duration("PRESENCE_INTERVAL", &c.Presence.Interval)
+
+	return errors.Join(errs...)
+}
+
+// Validate checks every value of the configuration and reports all the invalid ones
+// The Discord token is not required here since the CLI commands don't connect to Discord, see RequireToken
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Discord.OwnerID != "" && !isSnowflake(c.Discord.OwnerID) {
+		errs = append(errs, fmt.Errorf("discord.owner_id (OWNER_ID) must be a Discord user ID, got '%s'", c.Discord.OwnerID))
+	}
+	if c.Discord.ErrorChannelID != "" && !isSnowflake(c.Discord.ErrorChannelID) {
+		errs = append(errs, fmt.Errorf("discord.error_channel_id (ERROR_CHANNEL_ID) must be a Discord channel ID, got '%s'", c.Discord.ErrorChannelID))
+	}
+	for _, guildID := range c.Discord.DevGuildIDs {
+		if !isSnowflake(guildID) {
+			errs = append(errs, fmt.Errorf("discord.dev_guild_ids (DEV_GUILD_IDS) must only contain Discord guild IDs, got '%s'", guildID))
+		}
+	}
+	if c.Discord.DownloadTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("discord.download_timeout (DOWNLOAD_TIMEOUT) must be positive"))
+	}
+	if c.Discord.ShardCount < 0 {
+		errs = append(errs, fmt.Errorf("discord.shard_count (SHARD_COUNT) must not be negative, got %d", c.Discord.ShardCount))
+	}
+
+	if u, err := url.Parse(c.Overwatch.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("overwatch.api_url (OVERFAST_API_URL) must be an http or https URL, got '%s'", c.Overwatch.APIURL))
+	}
+	if c.Overwatch.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("overwatch.timeout (OVERFAST_TIMEOUT) must be positive"))
+	}
+	if c.Overwatch.GameDataTTL <= 0 {
+		errs = append(errs, fmt.Errorf("overwatch.game_data_ttl (GAME_DATA_TTL) must be positive"))
+	}
+
+	if c.Database.DSN == "" {
+		errs = append(errs, fmt.Errorf("database.dsn (DATABASE_URL or DATABASE_PATH) is required"))
+	}
+	if c.Database.RemovedGuildGracePeriod < 0 {
+		errs = append(errs, fmt.Errorf("database.removed_guild_grace_period (REMOVED_GUILD_GRACE_PERIOD) must not be negative"))
+	}
+
+	if c.Backup.Interval < 0 {
+		errs = append(errs, fmt.Errorf("backup.interval (BACKUP_INTERVAL) must be positive, or zero to disable scheduled backups"))
+	}
+	if c.Backup.Keep < 1 {
+		errs = append(errs, fmt.Errorf("backup.keep (BACKUP_KEEP) must be at least 1, got %d", c.Backup.Keep))
+	}
+	if c.Backup.Dir == "" {
+		errs = append(errs, fmt.Errorf("backup.dir (BACKUP_DIR) is required"))
+	}
+
+	if c.HTTP.Addr != "" {
+		if _, port, err := net.SplitHostPort(c.HTTP.Addr); err != nil || port == "" {
+			errs = append(errs, fmt.Errorf("http.addr (HTTP_ADDR) must be a host:port address (e.g. :9090), got '%s'", c.HTTP.Addr))
+		}
+	}
+
+	if c.Shutdown.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("shutdown.timeout (SHUTDOWN_TIMEOUT) must be positive"))
+	}
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level (LOG_LEVEL) must be debug, info, warn or error, got '%s'", c.Log.Level))
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("log.format (LOG_FORMAT) must be text or json, got '%s'", c.Log.Format))
+	}
+
+	for _, template := range c.Presence.Templates {
+		if err := presence.Validate(template); err != nil {
+			errs = append(errs, fmt.Errorf("presence.templates (PRESENCE_TEMPLATES): %w", err))
+		}
+	}
+	if c.Presence.Interval < presence.MinInterval {
+		errs = append(errs, fmt.Errorf("presence.interval (PRESENCE_INTERVAL) must be at least %s, got %s", presence.MinInterval, c.Presence.Interval))
+	}
+
+	return errors.Join(errs...)
+}
+
+// RequireToken returns an error if the Discord token is missing
+func (c *Config) RequireToken() error {
+	if c.Discord.Token == "" {
+		return fmt.Errorf("discord.token (DISCORD_TOKEN) is required")
+	}
+	return nil
+}
+
+// isSnowflake reports whether value looks like a Discord ID
+func isSnowflake(value string) bool {
+	_, err := strconv.ParseUint(value, 10, 64)
+	return err == nil
+}
+
+// splitList splits a list separated by sep, ignoring blank values
+func splitList(value, sep string) []string {
+	var values []string
+	for _, part := range strings.Split(value, sep) {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
@@ -0,0 +1,322 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/config"
+)
+
+// envVars are the variables read by Load, they are cleared so the environment of the tests doesn't leak in
+var envVars = []string{
+	"DISCORD_TOKEN", "OWNER_ID", "ERROR_CHANNEL_ID", "DEV_GUILD_IDS", "DOWNLOAD_TIMEOUT", "SHARD_COUNT",
+	"OVERFAST_API_URL", "OVERFAST_TIMEOUT", "GAME_DATA_TTL",
+	"DATABASE_PATH", "DATABASE_URL", "DATABASE_AUTO_MIGRATE", "REMOVED_GUILD_GRACE_PERIOD",
+	"BACKUP_DIR", "BACKUP_INTERVAL", "BACKUP_KEEP",
+	"LOG_LEVEL", "LOG_FORMAT", "LOG_REDACT", "LOG_REDACT_KEY",
+	"HTTP_ADDR", "SHUTDOWN_TIMEOUT",
+	"FEATURE_CONTEXT_MENUS", "FEATURE_REGISTRATION_TRANSFER", "FEATURE_COOLDOWNS",
+	"PRESENCE_TEMPLATES", "PRESENCE_INTERVAL",
+}
+
+// setEnv clears the variables read by Load then sets env, empty values are ignored by Load
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range envVars {
+		t.Setenv(name, "")
+	}
+	for name, value := range env {
+		t.Setenv(name, value)
+	}
+}
+
+// writeFile writes a configuration file in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+		check   func(t *testing.T, cfg *config.Config)
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+discord:
+  owner_id: "123456789012345678"
+  dev_guild_ids: ["223456789012345678"]
+log:
+  level: debug
+presence:
+  interval: 1m
+`,
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.Discord.OwnerID != "123456789012345678" || len(cfg.Discord.DevGuildIDs) != 1 {
+					t.Errorf("discord = %+v, want the owner and one dev guild", cfg.Discord)
+				}
+				if cfg.Log.Level != "debug" || cfg.Presence.Interval != time.Minute {
+					t.Errorf("log level = %s, presence interval = %s, want debug and 1m", cfg.Log.Level, cfg.Presence.Interval)
+				}
+			},
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			content: `
+[database]
+dsn = "bot.db"
+auto_migrate = false
+
+[features]
+cooldowns = false
+`,
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.Database.DSN != "bot.db" || cfg.Database.AutoMigrate || cfg.Features.Cooldowns {
+					t.Errorf("config = %+v, want the values of the file", cfg)
+				}
+			},
+		},
+		{
+			name:    "empty yaml",
+			file:    "config.yml",
+			content: "",
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.Log.Level != config.Default().Log.Level {
+					t.Errorf("log level = %s, want the default", cfg.Log.Level)
+				}
+			},
+		},
+		{
+			name:    "unknown yaml key",
+			file:    "config.yaml",
+			content: "log:\n  colour: true\n",
+			wantErr: "invalid config file",
+		},
+		{
+			name:    "unknown toml key",
+			file:    "config.toml",
+			content: "[log]\ncolour = true\n",
+			wantErr: "unknown key log.colour",
+		},
+		{
+			name:    "unsupported extension",
+			file:    "config.json",
+			content: "{}",
+			wantErr: "unsupported config file extension '.json'",
+		},
+		{
+			name:    "invalid values",
+			file:    "config.yaml",
+			content: "log:\n  level: verbose\n",
+			wantErr: "log.level (LOG_LEVEL) must be debug, info, warn or error, got 'verbose'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, nil)
+
+			cfg, err := config.Load(writeFile(t, tt.file, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	setEnv(t, nil)
+
+	if _, err := config.Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("error = nil, want the file to be missing")
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr []string
+		check   func(t *testing.T, cfg *config.Config)
+	}{
+		{
+			name: "overrides",
+			env: map[string]string{
+				"DISCORD_TOKEN":      "token",
+				"DEV_GUILD_IDS":      "123456789012345678, ,223456789012345678",
+				"SHARD_COUNT":        "4",
+				"LOG_REDACT":         "true",
+				"BACKUP_INTERVAL":    "24h",
+				"PRESENCE_TEMPLATES": "Overwatch, live;{guilds} servers",
+			},
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.Discord.Token != "token" || len(cfg.Discord.DevGuildIDs) != 2 || cfg.Discord.ShardCount != 4 {
+					t.Errorf("discord = %+v, want the values of the environment", cfg.Discord)
+				}
+				if !cfg.Log.Redact || cfg.Backup.Interval != 24*time.Hour {
+					t.Errorf("redact = %t, backup interval = %s, want true and 24h", cfg.Log.Redact, cfg.Backup.Interval)
+				}
+				if len(cfg.Presence.Templates) != 2 || cfg.Presence.Templates[0] != "Overwatch, live" {
+					t.Errorf("templates = %q, want them split on semicolons", cfg.Presence.Templates)
+				}
+			},
+		},
+		{
+			name: "database URL over path",
+			env: map[string]string{
+				"DATABASE_PATH": "bot.db",
+				"DATABASE_URL":  "postgres://bot@localhost/bot",
+			},
+			check: func(t *testing.T, cfg *config.Config) {
+				if cfg.Database.DSN != "postgres://bot@localhost/bot" {
+					t.Errorf("dsn = %s, want DATABASE_URL", cfg.Database.DSN)
+				}
+			},
+		},
+		{
+			name: "parse errors",
+			env: map[string]string{
+				"SHARD_COUNT":      "many",
+				"OVERFAST_TIMEOUT": "10",
+				"LOG_REDACT":       "maybe",
+			},
+			wantErr: []string{
+				"SHARD_COUNT must be an integer, got 'many'",
+				"OVERFAST_TIMEOUT must be a duration",
+				"LOG_REDACT must be true or false, got 'maybe'",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+
+			cfg, err := config.Load("")
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("error = nil, want %q", tt.wantErr)
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Errorf("error = %v, want %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	setEnv(t, map[string]string{
+		"LOG_LEVEL":    "warn",
+		"DATABASE_URL": "postgres://bot@localhost/bot",
+	})
+	path := writeFile(t, "config.yaml", `
+log:
+  level: debug
+  format: json
+database:
+  dsn: bot.db
+`)
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"environment over file", cfg.Log.Level, "warn"},
+		{"file over default", cfg.Log.Format, "json"},
+		{"DATABASE_URL over file", cfg.Database.DSN, "postgres://bot@localhost/bot"},
+		{"default", cfg.Overwatch.APIURL, config.Default().Overwatch.APIURL},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := config.Default().Validate(); err != nil {
+		t.Fatalf("default configuration is invalid: %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Discord.OwnerID = "owner"
+	cfg.Discord.ShardCount = -1
+	cfg.Overwatch.APIURL = "ftp://example.com"
+	cfg.Database.DSN = ""
+	cfg.Backup.Keep = 0
+	cfg.HTTP.Addr = "9090"
+	cfg.Log.Format = "xml"
+	cfg.Presence.Interval = time.Second
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("error = nil, want every invalid value reported")
+	}
+
+	// every invalid value is reported at once, on its own line
+	want := []string{
+		"discord.owner_id (OWNER_ID)",
+		"discord.shard_count (SHARD_COUNT)",
+		"overwatch.api_url (OVERFAST_API_URL)",
+		"database.dsn (DATABASE_URL or DATABASE_PATH)",
+		"backup.keep (BACKUP_KEEP)",
+		"http.addr (HTTP_ADDR)",
+		"log.format (LOG_FORMAT)",
+		"presence.interval (PRESENCE_INTERVAL)",
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != len(want) {
+		t.Errorf("got %d errors, want %d: %v", len(lines), len(want), err)
+	}
+	for _, field := range want {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("error = %v, want %s reported", err, field)
+		}
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	cfg := config.Default()
+	if err := cfg.RequireToken(); err == nil {
+		t.Error("error = nil, want the token required")
+	}
+
+	cfg.Discord.Token = "token"
+	if err := cfg.RequireToken(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
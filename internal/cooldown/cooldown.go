@@ -0,0 +1,154 @@
+// Package cooldown limits how often commands can be used, per user or per guild
+package cooldown
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the expired uses are removed from the tracker
+const sweepInterval = 10 * time.Minute
+
+// Scope is who shares the uses of a limit
+type Scope int
+
+const (
+	// ScopeUser counts the uses of every user separately
+	ScopeUser Scope = iota
+
+	// ScopeGuild counts the uses of a whole guild together, uses in direct messages are counted per user
+	ScopeGuild
+
+	// ScopeGlobal counts every use of the command together
+	ScopeGlobal
+)
+
+// String returns the name of the scope
+func (s Scope) String() string {
+	switch s {
+	case ScopeGuild:
+		return "guild"
+	case ScopeGlobal:
+		return "global"
+	default:
+		return "user"
+	}
+}
+
+// Limit allows Uses uses of a command within Per
+type Limit struct {
+	Uses   int
+	Per    time.Duration
+	Scope  Scope
+	Bucket string // counts the uses of every command with the same bucket together, empty to count the command alone
+}
+
+// PerUser allows a user to use a command uses times within per
+func PerUser(uses int, per time.Duration) Limit {
+	return Limit{Uses: uses, Per: per, Scope: ScopeUser}
+}
+
+// PerGuild allows a guild to use a command uses times within per
+func PerGuild(uses int, per time.Duration) Limit {
+	return Limit{Uses: uses, Per: per, Scope: ScopeGuild}
+}
+
+// Enabled reports whether the limit restricts anything
+func (l Limit) Enabled() bool {
+	return l.Uses > 0 && l.Per > 0
+}
+
+// Shared returns the limit counting its uses with the other commands of bucket
+func (l Limit) Shared(bucket string) Limit {
+	l.Bucket = bucket
+	return l
+}
+
+// Key returns the bucket of a use of command by userID in guildID
+func (l Limit) Key(command, guildID, userID string) string {
+	if l.Bucket != "" {
+		command = l.Bucket
+	}
+
+	switch {
+	case l.Scope == ScopeGlobal:
+		return command
+	case l.Scope == ScopeGuild && guildID != "":
+		return command + "/guild/" + guildID
+	default:
+		return command + "/user/" + userID
+	}
+}
+
+// Tracker records the uses of commands over a sliding window, it is safe for concurrent use
+type Tracker struct {
+	mu        sync.Mutex
+	uses      map[string][]time.Time
+	maxWindow time.Duration // longest window of the limits seen, buckets used within it are kept by sweeps
+	lastSweep time.Time
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{
+		uses:      make(map[string][]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take records a use in the bucket key if the limit allows it
+// Otherwise it returns false and the time until the next use is allowed
+func (t *Tracker) Take(key string, limit Limit) (bool, time.Duration) {
+	if !limit.Enabled() {
+		return true, 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if limit.Per > t.maxWindow {
+		t.maxWindow = limit.Per
+	}
+	t.sweep(now)
+
+	uses := recent(t.uses[key], now, limit.Per)
+	if len(uses) >= limit.Uses {
+		t.uses[key] = uses
+		return false, uses[len(uses)-limit.Uses].Add(limit.Per).Sub(now)
+	}
+
+	t.uses[key] = append(uses, now)
+	return true, 0
+}
+
+// Reset forgets the uses of a bucket
+func (t *Tracker) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.uses, key)
+}
+
+// sweep drops the buckets without recent uses so the tracker doesn't grow forever
+func (t *Tracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < sweepInterval {
+		return
+	}
+	t.lastSweep = now
+
+	for key, uses := range t.uses {
+		if len(uses) == 0 || now.Sub(uses[len(uses)-1]) >= t.maxWindow {
+			delete(t.uses, key)
+		}
+	}
+}
+
+// recent returns the uses within the window, uses are sorted from oldest to newest
+func recent(uses []time.Time, now time.Time, window time.Duration) []time.Time {
+	n := 0
+	for n < len(uses) && now.Sub(uses[n]) >= window {
+		n++
+	}
+	return uses[n:]
+}
@@ -0,0 +1,124 @@
+package cooldown_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/cooldown"
+)
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   cooldown.Limit
+		guildID string
+		want    string
+	}{
+		{"per user", cooldown.PerUser(1, time.Minute), "guild", "profile/user/user"},
+		{"per user in direct messages", cooldown.PerUser(1, time.Minute), "", "profile/user/user"},
+		{"per guild", cooldown.PerGuild(1, time.Minute), "guild", "profile/guild/guild"},
+		{"per guild in direct messages", cooldown.PerGuild(1, time.Minute), "", "profile/user/user"},
+		{"global", cooldown.Limit{Uses: 1, Per: time.Minute, Scope: cooldown.ScopeGlobal}, "guild", "profile"},
+		{"shared per user", cooldown.PerUser(1, time.Minute).Shared("stats"), "guild", "stats/user/user"},
+		{"shared per guild", cooldown.PerGuild(1, time.Minute).Shared("stats"), "guild", "stats/guild/guild"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.limit.Key("profile", tt.guildID, "user"); got != tt.want {
+				t.Errorf("Key() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTake(t *testing.T) {
+	type use struct {
+		key     string
+		allowed bool
+	}
+
+	tests := []struct {
+		name  string
+		limit cooldown.Limit
+		uses  []use
+	}{
+		{
+			name:  "within the limit",
+			limit: cooldown.PerUser(2, time.Minute),
+			uses:  []use{{"a", true}, {"a", true}, {"a", false}, {"a", false}},
+		},
+		{
+			name:  "separate buckets",
+			limit: cooldown.PerUser(1, time.Minute),
+			uses:  []use{{"a", true}, {"b", true}, {"a", false}, {"b", false}},
+		},
+		{
+			name:  "disabled",
+			limit: cooldown.PerUser(0, time.Minute),
+			uses:  []use{{"a", true}, {"a", true}, {"a", true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := cooldown.NewTracker()
+			for n, use := range tt.uses {
+				allowed, retryAfter := tracker.Take(use.key, tt.limit)
+				if allowed != use.allowed {
+					t.Fatalf("use %d of %s: allowed = %t, want %t", n, use.key, allowed, use.allowed)
+				}
+				if allowed && retryAfter != 0 {
+					t.Errorf("use %d of %s: retry after %s, want 0", n, use.key, retryAfter)
+				}
+				if !allowed && (retryAfter <= 0 || retryAfter > tt.limit.Per) {
+					t.Errorf("use %d of %s: retry after %s, want within %s", n, use.key, retryAfter, tt.limit.Per)
+				}
+			}
+		})
+	}
+}
+
+func TestTakeSharedBucket(t *testing.T) {
+	tracker := cooldown.NewTracker()
+	limit := cooldown.PerUser(1, time.Minute).Shared("profile")
+
+	// the commands of a bucket count their uses together
+	if allowed, _ := tracker.Take(limit.Key("profile", "guild", "user"), limit); !allowed {
+		t.Fatal("first use refused")
+	}
+	if allowed, _ := tracker.Take(limit.Key("Overwatch profile", "guild", "user"), limit); allowed {
+		t.Error("use of another command of the bucket allowed, want it refused")
+	}
+	if allowed, _ := tracker.Take(limit.Key("profile", "guild", "other"), limit); !allowed {
+		t.Error("use by another user refused")
+	}
+}
+
+func TestTakeSlidingWindow(t *testing.T) {
+	tracker := cooldown.NewTracker()
+	limit := cooldown.PerUser(1, 50*time.Millisecond)
+
+	if allowed, _ := tracker.Take("a", limit); !allowed {
+		t.Fatal("first use refused")
+	}
+	if allowed, _ := tracker.Take("a", limit); allowed {
+		t.Fatal("second use allowed within the window")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if allowed, _ := tracker.Take("a", limit); !allowed {
+		t.Error("use refused once the window passed")
+	}
+}
+
+func TestReset(t *testing.T) {
+	tracker := cooldown.NewTracker()
+	limit := cooldown.PerUser(1, time.Minute)
+
+	tracker.Take("a", limit)
+	tracker.Reset("a")
+	if allowed, _ := tracker.Take("a", limit); !allowed {
+		t.Error("use refused after a reset")
+	}
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	backupPrefix     = "juno-"
+	backupExtension  = ".db"
+	backupTimeLayout = "20060102-150405.000000000" // fixed width so names sort in time order, two backups in the same second don't collide
+)
+
+// ErrBackupUnsupported is returned when backing up or restoring a database that isn't stored in a SQLite file
+var ErrBackupUnsupported = errors.New("backups are only supported for SQLite databases stored on disk (use pg_dump for PostgreSQL)")
+
+// Backup writes an online, consistent snapshot of the database to dest using VACUUM INTO
+// dest must not exist yet, its parent directory is created if needed
+func (d *Database) Backup(dest string) error {
+	if d.driver != DriverSQLite {
+		return ErrBackupUnsupported
+	}
+
+	d.logger.WithField("dest", dest).Info("Backing up database...")
+
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup destination %s already exists", dest)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	start := time.Now()
+	if err := d.db.Exec("VACUUM INTO ?", dest).Error; err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	d.logger.WithFields(log.Fields{
+		"dest":     dest,
+		"duration": time.Since(start),
+	}).Info("Database backed up successfully")
+
+	return nil
+}
+
+// BackupToDir writes a timestamped backup in dir and returns its path
+func (d *Database) BackupToDir(dir string) (string, error) {
+	dest := filepath.Join(dir, backupPrefix+time.Now().UTC().Format(backupTimeLayout)+backupExtension)
+	if err := d.Backup(dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// RotateBackups deletes the oldest timestamped backups of dir, keeping only the most recent ones
+func RotateBackups(dir string, keep int, lgr *log.Logger) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupExtension) {
+			backups = append(backups, name)
+		}
+	}
+
+	if len(backups) <= keep {
+		return nil
+	}
+
+	// timestamps sort lexicographically, oldest first
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-keep] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return fmt.Errorf("failed to delete old backup %s: %w", name, err)
+		}
+		lgr.WithField("backup", name).Debug("Deleted old backup")
+	}
+
+	return nil
+}
+
+// Restore replaces the SQLite database pointed to by dsn with a backup file
+// The bot must not be running while restoring
+func Restore(backupPath, dsn string, lgr *log.Logger) error {
+	if DriverFromDSN(dsn) != DriverSQLite {
+		return ErrBackupUnsupported
+	}
+
+	_, _, dbPath, err := parseSQLiteDSN(dsn)
+	if err != nil {
+		return err
+	}
+	if dbPath == "" {
+		return ErrBackupUnsupported
+	}
+
+	lgr.WithFields(log.Fields{
+		"backup": backupPath,
+		"path":   dbPath,
+	}).Info("Restoring database from backup...")
+
+	if err := checkBackup(backupPath); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	// copy next to the database first so the final rename is atomic
+	tmpPath := dbPath + ".restore"
+	if err := copyFile(backupPath, tmpPath); err != nil {
+		return fmt.Errorf("failed to copy backup: %w", err)
+	}
+
+	// drop the WAL of the old database, it doesn't belong to the restored one
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to remove %s: %w", dbPath+suffix, err)
+		}
+	}
+
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+
+	lgr.WithField("path", dbPath).Info("Database restored successfully")
+	return nil
+}
+
+// checkBackup makes sure a backup file is a healthy SQLite database
+func checkBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+
+	db, err := gorm.Open(sqlite.Open("file:"+path+"?mode=ro"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql DB from gorm: %w", err)
+	}
+	defer sqlDB.Close()
+
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return fmt.Errorf("backup is not a valid SQLite database: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup failed integrity check: %s", result)
+	}
+
+	return nil
+}
+
+// copyFile copies src to dst, overwriting dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// BackupScheduler periodically backs up the database and keeps only the most recent backups
+type BackupScheduler struct {
+	db       BackupStore
+	dir      string
+	interval time.Duration
+	keep     int
+	logger   *log.Logger
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewBackupScheduler creates a scheduler writing a backup in dir every interval and keeping the last keep backups
+func NewBackupScheduler(db BackupStore, dir string, interval time.Duration, keep int, lgr *log.Logger) *BackupScheduler {
+	return &BackupScheduler{
+		db:       db,
+		dir:      dir,
+		interval: interval,
+		keep:     keep,
+		logger:   lgr,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start runs the scheduler in the background
+func (s *BackupScheduler) Start() {
+	s.logger.WithFields(log.Fields{
+		"dir":      s.dir,
+		"interval": s.interval,
+		"keep":     s.keep,
+	}).Info("Starting scheduled database backups")
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.runOnce()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the scheduler and waits for a running backup to finish
+func (s *BackupScheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// runOnce writes a backup and deletes the old ones
+func (s *BackupScheduler) runOnce() {
+	if _, err := s.db.BackupToDir(s.dir); err != nil {
+		s.logger.WithError(err).Error("Scheduled database backup failed")
+		return
+	}
+
+	if err := RotateBackups(s.dir, s.keep, s.logger); err != nil {
+		s.logger.WithError(err).Error("Failed to rotate database backups")
+	}
+}
@@ -1,28 +1,59 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// migrationLockID identifies the PostgreSQL advisory lock held while migrating
+const migrationLockID = 0x6a756e6f // "juno"
+
 type Database struct {
 	db     *gorm.DB
+	driver string
 	logger *log.Logger
 }
 
-// New creates a new Database instance
-func New(dbPath string, lgr *log.Logger) (*Database, error) {
-	lgr.WithField("path", dbPath).Info("Connecting to database...")
-
-	// create directory if it doesn't exist
-	if err := os.MkdirAll("data", 0755); err != nil {
-		return nil, fmt.Errorf("failed to create data directory: %w", err)
+// New creates a new Database instance, the schema isn't migrated, see Migrate
+// dsn is either a PostgreSQL URL (postgres://...) or a path to a SQLite database file
+func New(dsn string, lgr *log.Logger) (*Database, error) {
+	driver := DriverFromDSN(dsn)
+
+	lgr.WithFields(log.Fields{
+		"driver": driver,
+		"dsn":    RedactDSN(dsn),
+	}).Info("Connecting to database...")
+
+	var dialector gorm.Dialector
+	inMemory := false
+	switch driver {
+	case DriverPostgres:
+		dialector = postgres.Open(dsn)
+	default:
+		sqliteDSN, memory, err := prepareSQLite(dsn)
+		if err != nil {
+			return nil, err
+		}
+		dialector = sqlite.Open(sqliteDSN)
+		inMemory = memory
 	}
 
 	// config logger for gorm
@@ -34,26 +65,88 @@ func New(dbPath string, lgr *log.Logger) (*Database, error) {
 	}
 
 	// open database connection
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: gormLogger,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	// auto migrate schemas
-	lgr.Info("Migrating database models...")
-	if err := db.AutoMigrate(&UserRegistration{}); err != nil {
-		return nil, fmt.Errorf("error during migration: %w", err)
+	// every connection to an in-memory database opens a new empty one, so keep a single connection
+	if inMemory {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get sql DB from gorm: %w", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
 	}
 
 	lgr.Info("Database connection established successfully")
 	return &Database{
 		db:     db,
+		driver: driver,
 		logger: lgr,
 	}, nil
 }
 
+// Migrate creates or updates the tables of every model
+// On PostgreSQL the migrations run under an advisory lock so replicas starting together don't run them concurrently,
+// the ones waiting for the lock find the schema up to date
+func (d *Database) Migrate(ctx context.Context) error {
+	d.logger.Info("Migrating database models...")
+
+	migrate := func(db *gorm.DB) error {
+		if err := db.AutoMigrate(models()...); err != nil {
+			return fmt.Errorf("error during migration: %w", err)
+		}
+		return nil
+	}
+
+	if d.driver != DriverPostgres {
+		return migrate(d.db.WithContext(ctx))
+	}
+
+	// the lock belongs to the session, the migrations must run on the connection holding it
+	return d.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockID).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockID).Error; err != nil {
+				d.logger.WithError(err).Warn("Failed to release migration lock")
+			}
+		}()
+
+		return migrate(conn)
+	})
+}
+
+// DriverFromDSN returns the driver to use for the given DSN
+func DriverFromDSN(dsn string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return DriverPostgres
+	}
+	return DriverSQLite
+}
+
+// RedactDSN hides the password of a database URL so it can be logged safely
+func RedactDSN(dsn string) string {
+	if DriverFromDSN(dsn) != DriverPostgres {
+		return dsn
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "postgres://******"
+	}
+	return u.Redacted()
+}
+
+// Driver returns the name of the driver used by the database (sqlite or postgres)
+func (d *Database) Driver() string {
+	return d.driver
+}
+
 // Close closes the database connection
 func (d *Database) Close() error {
 	sqlDB, err := d.db.DB()
@@ -64,53 +157,110 @@ func (d *Database) Close() error {
 	return sqlDB.Close()
 }
 
+// Ping checks the database is reachable
+func (d *Database) Ping(ctx context.Context) error {
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql DB from gorm: %w", err)
+	}
+
+	return sqlDB.PingContext(ctx)
+}
+
 // RegisterUser registers a user with their BattleTag in the database for a specific guild
-func (d *Database) RegisterUser(guildID, userID, battleTag string) error {
-	d.logger.WithFields(log.Fields{
+func (d *Database) RegisterUser(ctx context.Context, guildID, userID, battleTag string) error {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
 		"guild_id":  guildID,
 		"user_id":   userID,
-		"battleTag": battleTag,
+		"battletag": battleTag,
 	}).Debug("Registering user in database")
 
+	if err := upsertRegistration(d.db.WithContext(ctx), guildID, userID, battleTag); err != nil {
+		return fmt.Errorf("failed to register user: %w", err)
+	}
+
+	d.logger.WithContext(ctx).WithFields(log.Fields{
+		"guild_id":  guildID,
+		"user_id":   userID,
+		"battletag": battleTag,
+	}).Info("User registered successfully")
+
+	return nil
+}
+
+// RegisterUsers links every user of battleTags to their BattleTag in a guild in a single transaction
+func (d *Database) RegisterUsers(ctx context.Context, guildID string, battleTags map[string]string) error {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
+		"guild_id": guildID,
+		"count":    len(battleTags),
+	}).Debug("Registering users in database")
+
+	// a fixed order keeps the row locks taken in the same order by concurrent imports
+	userIDs := slices.Sorted(maps.Keys(battleTags))
+
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, userID := range userIDs {
+			if err := upsertRegistration(tx, guildID, userID, battleTags[userID]); err != nil {
+				return fmt.Errorf("failed to register user %s: %w", userID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	d.logger.WithContext(ctx).WithFields(log.Fields{
+		"guild_id": guildID,
+		"count":    len(battleTags),
+	}).Info("Users registered successfully")
+
+	return nil
+}
+
+// upsertRegistration links a user to a BattleTag in a guild, replacing any previous link
+// The unique index also covers unregistered users, their row is restored as a new registration without the
+// preferences they had
+func upsertRegistration(db *gorm.DB, guildID, userID, battleTag string) error {
 	registration := UserRegistration{
 		GuildID:   guildID,
 		UserID:    userID,
 		BattleTag: battleTag,
 	}
 
-	result := d.db.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "guild_id"}, {Name: "user_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"battle_tag", "updated_at"}),
-	}).Create(&registration)
-
-	if result.Error != nil {
-		return fmt.Errorf("failed to register user: %w", result.Error)
+	// the expressions read the row as it was before the update, deleted_at is still set for an unregistered user
+	keepUnlessDeleted := func(column string) clause.Expr {
+		return gorm.Expr(fmt.Sprintf("CASE WHEN user_registrations.deleted_at IS NULL THEN user_registrations.%[1]s ELSE excluded.%[1]s END", column))
 	}
 
-	d.logger.WithFields(log.Fields{
-		"guild_id":  guildID,
-		"user_id":   userID,
-		"battleTag": battleTag,
-	}).Info("User registered successfully")
-
-	return nil
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "guild_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"battle_tag": gorm.Expr("excluded.battle_tag"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+			"created_at": keepUnlessDeleted("created_at"),
+			"platform":   keepUnlessDeleted("platform"),
+			"roles":      keepUnlessDeleted("roles"),
+			"deleted_at": nil,
+		}),
+	}).Create(&registration).Error
 }
 
 // GetUserBattleTag retrieves a user's BattleTag from the database for a specific guild
-func (d *Database) GetUserBattleTag(guildID, userID string) (string, error) {
-	d.logger.WithFields(log.Fields{
+func (d *Database) GetUserBattleTag(ctx context.Context, guildID, userID string) (string, error) {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
 		"guild_id": guildID,
 		"user_id":  userID,
 	}).Debug("Retrieving user BattleTag from database")
 
 	var registration UserRegistration
-	result := d.db.Where(&UserRegistration{
-		GuildID: guildID,
-		UserID:  userID,
+	result := d.db.WithContext(ctx).Where(map[string]any{
+		"guild_id": guildID,
+		"user_id":  userID,
 	}).First(&registration)
 
 	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
+		if result.Error == ErrNotFound {
 			return "", nil // user not found, return empty string
 		}
 		return "", fmt.Errorf("failed to retrieve user BattleTag: %w", result.Error)
@@ -119,16 +269,85 @@ func (d *Database) GetUserBattleTag(guildID, userID string) (string, error) {
 	return registration.BattleTag, nil
 }
 
+// GetUserRegistration retrieves the registration of a user in a guild, or nil if the user isn't registered
+func (d *Database) GetUserRegistration(ctx context.Context, guildID, userID string) (*UserRegistration, error) {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
+		"guild_id": guildID,
+		"user_id":  userID,
+	}).Debug("Retrieving user registration from database")
+
+	var registration UserRegistration
+	result := d.db.WithContext(ctx).Where(map[string]any{
+		"guild_id": guildID,
+		"user_id":  userID,
+	}).First(&registration)
+
+	if result.Error != nil {
+		if result.Error == ErrNotFound {
+			return nil, nil // user not found
+		}
+		return nil, fmt.Errorf("failed to retrieve user registration: %w", result.Error)
+	}
+
+	return &registration, nil
+}
+
+// SetUserPreferences saves the preferred platform and roles of a registered user
+func (d *Database) SetUserPreferences(ctx context.Context, guildID, userID, platform string, roles []string) error {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
+		"guild_id": guildID,
+		"user_id":  userID,
+		"platform": platform,
+		"roles":    roles,
+	}).Debug("Saving user preferences in database")
+
+	result := d.db.WithContext(ctx).Model(&UserRegistration{}).Where(map[string]any{
+		"guild_id": guildID,
+		"user_id":  userID,
+	}).Updates(map[string]any{
+		"platform": platform,
+		"roles":    strings.Join(roles, ","),
+	})
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to save user preferences: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetUserBattleTags retrieves the distinct BattleTags a user linked across all guilds
+func (d *Database) GetUserBattleTags(ctx context.Context, userID string) ([]string, error) {
+	d.logger.WithContext(ctx).WithField("user_id", userID).Debug("Retrieving user BattleTags from database")
+
+	var battleTags []string
+	result := d.db.WithContext(ctx).Model(&UserRegistration{}).
+		Where(map[string]any{"user_id": userID}).
+		Distinct("battle_tag").
+		Order("battle_tag").
+		Pluck("battle_tag", &battleTags)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve user BattleTags: %w", result.Error)
+	}
+
+	return battleTags, nil
+}
+
 // UnregisterUser removes a user's registration from the database for a specific guild
-func (d *Database) UnregisterUser(guildID, userID string) error {
-	d.logger.WithFields(log.Fields{
+func (d *Database) UnregisterUser(ctx context.Context, guildID, userID string) error {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
 		"guild_id": guildID,
 		"user_id":  userID,
 	}).Debug("Unregistering user from database")
 
-	result := d.db.Where(&UserRegistration{
-		GuildID: guildID,
-		UserID:  userID,
+	result := d.db.WithContext(ctx).Where(map[string]any{
+		"guild_id": guildID,
+		"user_id":  userID,
 	}).Delete(&UserRegistration{})
 
 	if result.Error != nil {
@@ -136,10 +355,10 @@ func (d *Database) UnregisterUser(guildID, userID string) error {
 	}
 
 	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound // no record deleted, user not found
+		return ErrNotFound // no record deleted, user not found
 	}
 
-	d.logger.WithFields(log.Fields{
+	d.logger.WithContext(ctx).WithFields(log.Fields{
 		"guild_id": guildID,
 		"user_id":  userID,
 	}).Info("User unregistered successfully")
@@ -148,12 +367,12 @@ func (d *Database) UnregisterUser(guildID, userID string) error {
 }
 
 // GetGuildRegistrations retrieves all user registrations for a specific guild
-func (d *Database) GetGuildRegistrations(guildID string) ([]UserRegistration, error) {
-	d.logger.WithField("guild_id", guildID).Debug("Retrieving all user registrations for guild")
+func (d *Database) GetGuildRegistrations(ctx context.Context, guildID string) ([]UserRegistration, error) {
+	d.logger.WithContext(ctx).WithField("guild_id", guildID).Debug("Retrieving all user registrations for guild")
 
 	var registrations []UserRegistration
-	result := d.db.Where(&UserRegistration{
-		GuildID: guildID,
+	result := d.db.WithContext(ctx).Where(map[string]any{
+		"guild_id": guildID,
 	}).Find(&registrations)
 
 	if result.Error != nil {
@@ -163,10 +382,34 @@ func (d *Database) GetGuildRegistrations(guildID string) ([]UserRegistration, er
 	return registrations, nil
 }
 
+// DeleteGuildRegistrations removes every user registration of a specific guild
+func (d *Database) DeleteGuildRegistrations(ctx context.Context, guildID string) (int64, error) {
+	d.logger.WithContext(ctx).WithField("guild_id", guildID).Debug("Deleting all user registrations of guild")
+
+	// the registrations are deleted for good, the rows of the unregistered users too but they aren't counted
+	var removed int64
+	err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("guild_id = ? AND deleted_at IS NOT NULL", guildID).Delete(&UserRegistration{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where(map[string]any{
+			"guild_id": guildID,
+		}).Delete(&UserRegistration{})
+		removed = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete guild registrations: %w", err)
+	}
+
+	return removed, nil
+}
+
 // GetUserStats returns number of registered users
-func (d *Database) GetUserStats() (int64, error) {
+func (d *Database) GetUserStats(ctx context.Context) (int64, error) {
 	var count int64
-	result := d.db.Model(&UserRegistration{}).Count(&count)
+	result := d.db.WithContext(ctx).Model(&UserRegistration{}).Count(&count)
 
 	if result.Error != nil {
 		return 0, fmt.Errorf("failed to count user registrations: %w", result.Error)
@@ -174,3 +417,52 @@ func (d *Database) GetUserStats() (int64, error) {
 
 	return count, nil
 }
+
+// GetGuildSettings retrieves the settings of a guild, falling back to the default settings
+func (d *Database) GetGuildSettings(ctx context.Context, guildID string) (*GuildSettings, error) {
+	d.logger.WithContext(ctx).WithField("guild_id", guildID).Debug("Retrieving guild settings from database")
+
+	var settings GuildSettings
+	result := d.db.WithContext(ctx).Where(map[string]any{
+		"guild_id": guildID,
+	}).First(&settings)
+
+	if result.Error != nil {
+		if result.Error == ErrNotFound {
+			return DefaultGuildSettings(guildID), nil
+		}
+		return nil, fmt.Errorf("failed to retrieve guild settings: %w", result.Error)
+	}
+
+	return &settings, nil
+}
+
+// SaveGuildSettings creates or updates the settings of a guild
+func (d *Database) SaveGuildSettings(ctx context.Context, settings *GuildSettings) error {
+	d.logger.WithContext(ctx).WithField("guild_id", settings.GuildID).Debug("Saving guild settings in database")
+
+	result := d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "guild_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"welcome_message", "removed_at", "updated_at"}),
+	}).Create(settings)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to save guild settings: %w", result.Error)
+	}
+
+	d.logger.WithContext(ctx).WithField("guild_id", settings.GuildID).Info("Guild settings saved successfully")
+
+	return nil
+}
+
+// GetRemovedGuilds retrieves the settings of the guilds the bot was removed from before a time
+func (d *Database) GetRemovedGuilds(ctx context.Context, before time.Time) ([]GuildSettings, error) {
+	var settings []GuildSettings
+	result := d.db.WithContext(ctx).Where("removed_at < ?", before).Find(&settings)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve removed guilds: %w", result.Error)
+	}
+
+	return settings, nil
+}
@@ -0,0 +1,57 @@
+package database_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/database/storetest"
+	log "github.com/sirupsen/logrus"
+)
+
+// postgresEnv holds the URL of the PostgreSQL database the suite runs against, it's skipped when empty
+// The suite only uses IDs of its own, the database can be shared but must not be a production one
+const postgresEnv = "TEST_DATABASE_URL"
+
+func TestSQLiteStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) database.Store {
+		return open(t, ":memory:")
+	})
+}
+
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv(postgresEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresEnv)
+	}
+	if database.DriverFromDSN(dsn) != database.DriverPostgres {
+		t.Fatalf("%s must be a PostgreSQL URL", postgresEnv)
+	}
+
+	storetest.Run(t, func(t *testing.T) database.Store {
+		return open(t, dsn)
+	})
+}
+
+// open opens and migrates a database, it's closed at the end of the test
+func open(t *testing.T, dsn string) *database.Database {
+	t.Helper()
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	db, err := database.New(dsn, logger)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	if err := db.Migrate(context.Background()); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm/clause"
+)
+
+// SetCommandToggle enables or disables a command in a guild
+func (d *Database) SetCommandToggle(ctx context.Context, guildID, command string, enabled bool) error {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
+		"guild_id": guildID,
+		"command":  command,
+		"enabled":  enabled,
+	}).Debug("Saving command toggle in database")
+
+	toggle := &CommandToggle{
+		GuildID: guildID,
+		Command: command,
+		Enabled: enabled,
+	}
+
+	result := d.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "guild_id"}, {Name: "command"}},
+		DoUpdates: clause.Assignments(map[string]any{"enabled": enabled, "updated_at": time.Now()}),
+	}).Create(toggle)
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to save command toggle: %w", result.Error)
+	}
+
+	return nil
+}
+
+// RemoveCommandToggle removes the toggle of a command in a guild
+func (d *Database) RemoveCommandToggle(ctx context.Context, guildID, command string) error {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
+		"guild_id": guildID,
+		"command":  command,
+	}).Debug("Removing command toggle from database")
+
+	result := d.db.WithContext(ctx).Where(map[string]any{
+		"guild_id": guildID,
+		"command":  command,
+	}).Delete(&CommandToggle{})
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove command toggle: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetCommandToggles retrieves the toggles of every guild
+func (d *Database) GetCommandToggles(ctx context.Context) ([]CommandToggle, error) {
+	var toggles []CommandToggle
+	result := d.db.WithContext(ctx).Order("command, guild_id").Find(&toggles)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve command toggles: %w", result.Error)
+	}
+
+	return toggles, nil
+}
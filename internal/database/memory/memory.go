@@ -0,0 +1,410 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/database"
+)
+
+// Store is an in-memory implementation of database.Store, meant for tests and local experiments
+type Store struct {
+	mu            sync.RWMutex
+	nextID        uint
+	registrations map[registrationKey]database.UserRegistration
+	settings      map[string]database.GuildSettings
+	restrictions  map[restrictionKey]database.CommandRestriction
+	toggles       map[toggleKey]database.CommandToggle
+}
+
+type registrationKey struct {
+	guildID string
+	userID  string
+}
+
+type toggleKey struct {
+	guildID string
+	command string
+}
+
+type restrictionKey struct {
+	guildID  string
+	command  string
+	kind     string
+	targetID string
+}
+
+// ensure Store implements database.Store
+var _ database.Store = (*Store)(nil)
+
+// New creates a new empty in-memory store
+func New() *Store {
+	return &Store{
+		registrations: make(map[registrationKey]database.UserRegistration),
+		settings:      make(map[string]database.GuildSettings),
+		restrictions:  make(map[restrictionKey]database.CommandRestriction),
+		toggles:       make(map[toggleKey]database.CommandToggle),
+	}
+}
+
+// RegisterUser links a user to a BattleTag in a guild, replacing any previous link
+func (s *Store) RegisterUser(ctx context.Context, guildID, userID, battleTag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.register(guildID, userID, battleTag)
+	return nil
+}
+
+// RegisterUsers links every user of battleTags to their BattleTag in a guild, all at once
+func (s *Store) RegisterUsers(ctx context.Context, guildID string, battleTags map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID, battleTag := range battleTags {
+		s.register(guildID, userID, battleTag)
+	}
+	return nil
+}
+
+// register links a user to a BattleTag in a guild, the caller must hold the lock
+func (s *Store) register(guildID, userID, battleTag string) {
+	now := time.Now()
+	key := registrationKey{guildID: guildID, userID: userID}
+
+	registration, exists := s.registrations[key]
+	if !exists {
+		s.nextID++
+		registration = database.UserRegistration{
+			ID:        s.nextID,
+			CreatedAt: now,
+			GuildID:   guildID,
+			UserID:    userID,
+		}
+	}
+	registration.BattleTag = battleTag
+	registration.UpdatedAt = now
+
+	s.registrations[key] = registration
+}
+
+// GetUserBattleTag returns the BattleTag of a user in a guild, or an empty string if the user isn't registered
+func (s *Store) GetUserBattleTag(ctx context.Context, guildID, userID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.registrations[registrationKey{guildID: guildID, userID: userID}].BattleTag, nil
+}
+
+// GetUserRegistration returns the registration of a user in a guild, or nil if the user isn't registered
+func (s *Store) GetUserRegistration(ctx context.Context, guildID, userID string) (*database.UserRegistration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	registration, exists := s.registrations[registrationKey{guildID: guildID, userID: userID}]
+	if !exists {
+		return nil, nil
+	}
+	return &registration, nil
+}
+
+// SetUserPreferences saves the preferred platform and roles of a registered user
+func (s *Store) SetUserPreferences(ctx context.Context, guildID, userID, platform string, roles []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := registrationKey{guildID: guildID, userID: userID}
+	registration, exists := s.registrations[key]
+	if !exists {
+		return database.ErrNotFound
+	}
+
+	registration.Platform = platform
+	registration.Roles = strings.Join(roles, ",")
+	registration.UpdatedAt = time.Now()
+
+	s.registrations[key] = registration
+	return nil
+}
+
+// GetUserBattleTags returns the distinct BattleTags a user linked across all guilds
+func (s *Store) GetUserBattleTags(ctx context.Context, userID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var battleTags []string
+	for key, registration := range s.registrations {
+		if key.userID == userID && !seen[registration.BattleTag] {
+			seen[registration.BattleTag] = true
+			battleTags = append(battleTags, registration.BattleTag)
+		}
+	}
+
+	sort.Strings(battleTags)
+	return battleTags, nil
+}
+
+// UnregisterUser removes the link of a user in a guild
+func (s *Store) UnregisterUser(ctx context.Context, guildID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := registrationKey{guildID: guildID, userID: userID}
+	if _, exists := s.registrations[key]; !exists {
+		return database.ErrNotFound
+	}
+
+	delete(s.registrations, key)
+	return nil
+}
+
+// GetGuildRegistrations returns every registration of a guild, ordered by creation
+func (s *Store) GetGuildRegistrations(ctx context.Context, guildID string) ([]database.UserRegistration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var registrations []database.UserRegistration
+	for key, registration := range s.registrations {
+		if key.guildID == guildID {
+			registrations = append(registrations, registration)
+		}
+	}
+
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].ID < registrations[j].ID
+	})
+	return registrations, nil
+}
+
+// GetUserStats returns the number of registrations across all guilds
+func (s *Store) GetUserStats(ctx context.Context) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return int64(len(s.registrations)), nil
+}
+
+// DeleteGuildRegistrations removes every registration of a guild and returns how many were removed
+func (s *Store) DeleteGuildRegistrations(ctx context.Context, guildID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64
+	for key := range s.registrations {
+		if key.guildID == guildID {
+			delete(s.registrations, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// GetGuildSettings returns the settings of a guild, or the default settings if none were saved
+func (s *Store) GetGuildSettings(ctx context.Context, guildID string) (*database.GuildSettings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	settings, exists := s.settings[guildID]
+	if !exists {
+		return database.DefaultGuildSettings(guildID), nil
+	}
+	return &settings, nil
+}
+
+// SaveGuildSettings creates or updates the settings of a guild
+func (s *Store) SaveGuildSettings(ctx context.Context, settings *database.GuildSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	existing, exists := s.settings[settings.GuildID]
+	if exists {
+		settings.ID = existing.ID
+		settings.CreatedAt = existing.CreatedAt
+	} else {
+		s.nextID++
+		settings.ID = s.nextID
+		settings.CreatedAt = now
+	}
+	settings.UpdatedAt = now
+
+	s.settings[settings.GuildID] = *settings
+	return nil
+}
+
+// GetRemovedGuilds returns the settings of the guilds the bot was removed from before a time, ordered by guild ID
+func (s *Store) GetRemovedGuilds(ctx context.Context, before time.Time) ([]database.GuildSettings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var removed []database.GuildSettings
+	for _, settings := range s.settings {
+		if settings.RemovedAt != nil && settings.RemovedAt.Before(before) {
+			removed = append(removed, settings)
+		}
+	}
+
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].GuildID < removed[j].GuildID
+	})
+	return removed, nil
+}
+
+// AddCommandRestriction restricts a command of a guild to a role or a channel
+func (s *Store) AddCommandRestriction(ctx context.Context, guildID, command, kind, targetID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := restrictionKey{guildID: guildID, command: command, kind: kind, targetID: targetID}
+	if _, exists := s.restrictions[key]; exists {
+		return nil
+	}
+
+	s.nextID++
+	s.restrictions[key] = database.CommandRestriction{
+		ID:        s.nextID,
+		CreatedAt: time.Now(),
+		GuildID:   guildID,
+		Command:   command,
+		Kind:      kind,
+		TargetID:  targetID,
+	}
+	return nil
+}
+
+// RemoveCommandRestriction removes a restriction of a command in a guild
+func (s *Store) RemoveCommandRestriction(ctx context.Context, guildID, command, kind, targetID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := restrictionKey{guildID: guildID, command: command, kind: kind, targetID: targetID}
+	if _, exists := s.restrictions[key]; !exists {
+		return database.ErrNotFound
+	}
+
+	delete(s.restrictions, key)
+	return nil
+}
+
+// GetCommandRestrictions returns the restrictions of a command in a guild
+func (s *Store) GetCommandRestrictions(ctx context.Context, guildID, command string) ([]database.CommandRestriction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var restrictions []database.CommandRestriction
+	for key, restriction := range s.restrictions {
+		if key.guildID == guildID && key.command == command {
+			restrictions = append(restrictions, restriction)
+		}
+	}
+
+	sortRestrictions(restrictions)
+	return restrictions, nil
+}
+
+// GetGuildRestrictions returns every restriction of a guild, ordered by command
+func (s *Store) GetGuildRestrictions(ctx context.Context, guildID string) ([]database.CommandRestriction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var restrictions []database.CommandRestriction
+	for key, restriction := range s.restrictions {
+		if key.guildID == guildID {
+			restrictions = append(restrictions, restriction)
+		}
+	}
+
+	sortRestrictions(restrictions)
+	return restrictions, nil
+}
+
+// ClearCommandRestrictions removes every restriction of a command in a guild
+func (s *Store) ClearCommandRestrictions(ctx context.Context, guildID, command string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed int64
+	for key := range s.restrictions {
+		if key.guildID == guildID && key.command == command {
+			delete(s.restrictions, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// sortRestrictions orders restrictions like the database does, by command, kind and creation
+func sortRestrictions(restrictions []database.CommandRestriction) {
+	sort.Slice(restrictions, func(i, j int) bool {
+		a, b := restrictions[i], restrictions[j]
+		if a.Command != b.Command {
+			return a.Command < b.Command
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.ID < b.ID
+	})
+}
+
+// SetCommandToggle enables or disables a command in a guild
+func (s *Store) SetCommandToggle(ctx context.Context, guildID, command string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key := toggleKey{guildID: guildID, command: command}
+
+	toggle, exists := s.toggles[key]
+	if !exists {
+		s.nextID++
+		toggle = database.CommandToggle{
+			ID:        s.nextID,
+			CreatedAt: now,
+			GuildID:   guildID,
+			Command:   command,
+		}
+	}
+	toggle.Enabled = enabled
+	toggle.UpdatedAt = now
+
+	s.toggles[key] = toggle
+	return nil
+}
+
+// RemoveCommandToggle removes the toggle of a command in a guild
+func (s *Store) RemoveCommandToggle(ctx context.Context, guildID, command string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := toggleKey{guildID: guildID, command: command}
+	if _, exists := s.toggles[key]; !exists {
+		return database.ErrNotFound
+	}
+
+	delete(s.toggles, key)
+	return nil
+}
+
+// GetCommandToggles returns the toggles of every guild, ordered by command
+func (s *Store) GetCommandToggles(ctx context.Context) ([]database.CommandToggle, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	toggles := make([]database.CommandToggle, 0, len(s.toggles))
+	for _, toggle := range s.toggles {
+		toggles = append(toggles, toggle)
+	}
+
+	sort.Slice(toggles, func(i, j int) bool {
+		if toggles[i].Command != toggles[j].Command {
+			return toggles[i].Command < toggles[j].Command
+		}
+		return toggles[i].GuildID < toggles[j].GuildID
+	})
+	return toggles, nil
+}
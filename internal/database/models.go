@@ -1,12 +1,28 @@
 package database
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// models returns every model that must be migrated, in migration order
+func models() []any {
+	return []any{
+		&UserRegistration{},
+		&GuildSettings{},
+		&CommandRestriction{},
+		&CommandToggle{},
+	}
+}
+
+// GlobalGuildID is the guild ID of global account links, made with /register in direct messages
+// They are used wherever the user has no registration of their own
+const GlobalGuildID = ""
+
 // UserRegistration represents a link between a Discord user and their BattleTag
+// GuildID is GlobalGuildID for global account links
 type UserRegistration struct {
 	ID        uint           `gorm:"primaryKey"`
 	CreatedAt time.Time      // Timestamp of when the registration was created
@@ -14,14 +30,105 @@ type UserRegistration struct {
 	DeletedAt gorm.DeletedAt `gorm:"index"` // Soft delete field
 
 	// foreign keys
-	GuildID string `gorm:"uniqueIndex:idx_user_guild;not null"` // Discord Guild ID
+	GuildID string `gorm:"uniqueIndex:idx_user_guild;not null"` // Discord Guild ID, empty for global account links
 	UserID  string `gorm:"uniqueIndex:idx_user_guild;not null"` // Discord User ID
 
 	// data
 	BattleTag string `gorm:"not null"` // User's BattleTag (e.g. "Player#1234")
+	Platform  string // Preferred platform for competitive ranks ("pc" or "console"), empty for PC
+	Roles     string // Preferred roles, comma separated (e.g. "tank,support")
+}
+
+// RoleList returns the preferred roles of the user
+func (r UserRegistration) RoleList() []string {
+	if r.Roles == "" {
+		return nil
+	}
+	return strings.Split(r.Roles, ",")
 }
 
 // TableName specifies the table name for UserRegistration
 func (UserRegistration) TableName() string {
 	return "user_registrations"
 }
+
+// Platforms supported for competitive ranks
+const (
+	PlatformPC      = "pc"
+	PlatformConsole = "console"
+)
+
+// Roles a user can prefer
+const (
+	RoleTank    = "tank"
+	RoleDamage  = "damage"
+	RoleSupport = "support"
+)
+
+// GuildSettings represents the configuration of the bot for a Discord guild
+type GuildSettings struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time // Timestamp of when the settings were created
+	UpdatedAt time.Time // Timestamp of when the settings were last updated
+
+	GuildID string `gorm:"uniqueIndex;not null"` // Discord Guild ID
+
+	// data
+	WelcomeMessage string // Message shown to the guild when the bot joins, empty for the default one
+
+	// lifecycle
+	RemovedAt *time.Time `gorm:"index"` // Timestamp of when the bot was removed from the guild, nil while it's a member
+}
+
+// TableName specifies the table name for GuildSettings
+func (GuildSettings) TableName() string {
+	return "guild_settings"
+}
+
+// DefaultGuildSettings returns the settings used for a guild that hasn't configured the bot
+func DefaultGuildSettings(guildID string) *GuildSettings {
+	return &GuildSettings{
+		GuildID: guildID,
+	}
+}
+
+// Kinds of command restrictions
+const (
+	RestrictionRole    = "role"
+	RestrictionChannel = "channel"
+)
+
+// CommandRestriction restricts a command in a guild to the members of a role or to a channel
+// A command with role restrictions can only be used by members having one of the roles,
+// a command with channel restrictions only in one of the channels
+type CommandRestriction struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time // Timestamp of when the restriction was added
+
+	GuildID  string `gorm:"uniqueIndex:idx_restriction;not null"` // Discord Guild ID
+	Command  string `gorm:"uniqueIndex:idx_restriction;not null"` // Name of the restricted command, lowercase
+	Kind     string `gorm:"uniqueIndex:idx_restriction;not null"` // RestrictionRole or RestrictionChannel
+	TargetID string `gorm:"uniqueIndex:idx_restriction;not null"` // ID of the role or the channel
+}
+
+// TableName specifies the table name for CommandRestriction
+func (CommandRestriction) TableName() string {
+	return "command_restrictions"
+}
+
+// CommandToggle enables or disables a command in a guild
+// Toggles with GlobalGuildID set the default of every guild, commands without toggles are enabled everywhere
+type CommandToggle struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time // Timestamp of when the toggle was created
+	UpdatedAt time.Time // Timestamp of when the toggle was last updated
+
+	GuildID string `gorm:"uniqueIndex:idx_toggle;not null"` // Discord Guild ID, empty for the default of every guild
+	Command string `gorm:"uniqueIndex:idx_toggle;not null"` // Name of the command, lowercase
+	Enabled bool   `gorm:"not null"`                        // Whether the command is registered in the guild
+}
+
+// TableName specifies the table name for CommandToggle
+func (CommandToggle) TableName() string {
+	return "command_toggles"
+}
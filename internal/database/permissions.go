@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm/clause"
+)
+
+// AddCommandRestriction restricts a command of a guild to a role or a channel
+func (d *Database) AddCommandRestriction(ctx context.Context, guildID, command, kind, targetID string) error {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
+		"guild_id":  guildID,
+		"command":   command,
+		"kind":      kind,
+		"target_id": targetID,
+	}).Debug("Adding command restriction to database")
+
+	restriction := &CommandRestriction{
+		GuildID:  guildID,
+		Command:  command,
+		Kind:     kind,
+		TargetID: targetID,
+	}
+
+	result := d.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(restriction)
+	if result.Error != nil {
+		return fmt.Errorf("failed to add command restriction: %w", result.Error)
+	}
+
+	return nil
+}
+
+// RemoveCommandRestriction removes a restriction of a command in a guild
+func (d *Database) RemoveCommandRestriction(ctx context.Context, guildID, command, kind, targetID string) error {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
+		"guild_id":  guildID,
+		"command":   command,
+		"kind":      kind,
+		"target_id": targetID,
+	}).Debug("Removing command restriction from database")
+
+	result := d.db.WithContext(ctx).Where(map[string]any{
+		"guild_id":  guildID,
+		"command":   command,
+		"kind":      kind,
+		"target_id": targetID,
+	}).Delete(&CommandRestriction{})
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove command restriction: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetCommandRestrictions retrieves the restrictions of a command in a guild
+func (d *Database) GetCommandRestrictions(ctx context.Context, guildID, command string) ([]CommandRestriction, error) {
+	var restrictions []CommandRestriction
+	result := d.db.WithContext(ctx).Where(map[string]any{
+		"guild_id": guildID,
+		"command":  command,
+	}).Find(&restrictions)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve command restrictions: %w", result.Error)
+	}
+
+	return restrictions, nil
+}
+
+// GetGuildRestrictions retrieves every restriction of a guild
+func (d *Database) GetGuildRestrictions(ctx context.Context, guildID string) ([]CommandRestriction, error) {
+	d.logger.WithContext(ctx).WithField("guild_id", guildID).Debug("Retrieving guild command restrictions from database")
+
+	var restrictions []CommandRestriction
+	result := d.db.WithContext(ctx).Where(map[string]any{
+		"guild_id": guildID,
+	}).Order("command, kind, id").Find(&restrictions)
+
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to retrieve guild command restrictions: %w", result.Error)
+	}
+
+	return restrictions, nil
+}
+
+// ClearCommandRestrictions removes every restriction of a command in a guild
+func (d *Database) ClearCommandRestrictions(ctx context.Context, guildID, command string) (int64, error) {
+	d.logger.WithContext(ctx).WithFields(log.Fields{
+		"guild_id": guildID,
+		"command":  command,
+	}).Debug("Clearing command restrictions from database")
+
+	result := d.db.WithContext(ctx).Where(map[string]any{
+		"guild_id": guildID,
+		"command":  command,
+	}).Delete(&CommandRestriction{})
+
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to clear command restrictions: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sqliteOptions are the connection options applied to SQLite databases unless already set in the DSN
+var sqliteOptions = []struct {
+	keys  []string // accepted spellings of the option, the first one is used when it's missing
+	value string
+	file  bool // only applies to databases stored on disk
+}{
+	{keys: []string{"_journal_mode", "_journal"}, value: "WAL", file: true},
+	{keys: []string{"_busy_timeout", "_timeout"}, value: "5000"},
+	{keys: []string{"_foreign_keys", "_fk"}, value: "on"},
+}
+
+// prepareSQLite creates the parent directory of a SQLite database and adds the default connection options to its DSN
+// It accepts plain paths, ":memory:" and "file:" URIs, and reports whether the database only lives in memory
+func prepareSQLite(dsn string) (string, bool, error) {
+	path, params, filePath, err := parseSQLiteDSN(dsn)
+	if err != nil {
+		return "", false, err
+	}
+
+	inMemory := filePath == ""
+
+	if !inMemory {
+		// create directory if it doesn't exist
+		if dir := filepath.Dir(filePath); dir != "." {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return "", false, fmt.Errorf("failed to create database directory %s: %w", dir, err)
+			}
+		}
+	}
+
+	for _, option := range sqliteOptions {
+		if option.file && inMemory {
+			continue
+		}
+		if !hasAnyParam(params, option.keys) {
+			params.Set(option.keys[0], option.value)
+		}
+	}
+
+	return path + "?" + params.Encode(), inMemory, nil
+}
+
+// parseSQLiteDSN splits a SQLite DSN into its path and connection options
+// filePath is the location of the database on disk, or an empty string for in-memory databases
+func parseSQLiteDSN(dsn string) (path string, params url.Values, filePath string, err error) {
+	path, rawQuery, _ := strings.Cut(dsn, "?")
+
+	params, err = url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("invalid SQLite connection options: %w", err)
+	}
+
+	if path == ":memory:" || strings.HasPrefix(path, "file::memory:") || params.Get("mode") == "memory" {
+		return path, params, "", nil
+	}
+
+	// "file:data/bot.db" and "file:///var/lib/juno/bot.db" both point to a file on disk
+	filePath = strings.TrimPrefix(strings.TrimPrefix(path, "file:"), "//")
+	return path, params, filePath, nil
+}
+
+// hasAnyParam reports whether one of the keys is set in params
+func hasAnyParam(params url.Values, keys []string) bool {
+	for _, key := range keys {
+		if _, ok := params[key]; ok {
+			return true
+		}
+	}
+	return false
+}
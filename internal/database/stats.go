@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+)
+
+// Stats summarizes the content of the database
+type Stats struct {
+	Driver           string
+	Registrations    int64 // registrations across all guilds, global links included
+	RegisteredUsers  int64 // distinct users with at least one registration
+	RegisteredGuilds int64 // distinct guilds with at least one registration, global links excluded
+	GlobalLinks      int64
+	GuildSettings    int64
+	Restrictions     int64
+	Toggles          int64
+}
+
+// Stats counts the rows of every table
+func (d *Database) Stats() (*Stats, error) {
+	stats := &Stats{Driver: d.driver}
+
+	counts := []struct {
+		name   string
+		target *int64
+		count  func(*int64) error
+	}{
+		{"registrations", &stats.Registrations, func(n *int64) error {
+			return d.db.Model(&UserRegistration{}).Count(n).Error
+		}},
+		{"registered users", &stats.RegisteredUsers, func(n *int64) error {
+			return d.db.Model(&UserRegistration{}).Distinct("user_id").Count(n).Error
+		}},
+		{"registered guilds", &stats.RegisteredGuilds, func(n *int64) error {
+			return d.db.Model(&UserRegistration{}).Where("guild_id <> ?", GlobalGuildID).Distinct("guild_id").Count(n).Error
+		}},
+		{"global links", &stats.GlobalLinks, func(n *int64) error {
+			return d.db.Model(&UserRegistration{}).Where(map[string]any{"guild_id": GlobalGuildID}).Count(n).Error
+		}},
+		{"guild settings", &stats.GuildSettings, func(n *int64) error {
+			return d.db.Model(&GuildSettings{}).Count(n).Error
+		}},
+		{"command restrictions", &stats.Restrictions, func(n *int64) error {
+			return d.db.Model(&CommandRestriction{}).Count(n).Error
+		}},
+		{"command toggles", &stats.Toggles, func(n *int64) error {
+			return d.db.Model(&CommandToggle{}).Count(n).Error
+		}},
+	}
+
+	for _, c := range counts {
+		if err := c.count(c.target); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", c.name, err)
+		}
+	}
+
+	return stats, nil
+}
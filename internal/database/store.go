@@ -0,0 +1,126 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when a record does not exist
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// RegistrationStore manages the links between Discord users and their BattleTags
+type RegistrationStore interface {
+	// RegisterUser links a user to a BattleTag in a guild, replacing any previous link
+	RegisterUser(ctx context.Context, guildID, userID, battleTag string) error
+
+	// RegisterUsers links every user of battleTags, keyed by user ID, to their BattleTag in a guild
+	// The links are saved in a single transaction, none is saved if one fails
+	RegisterUsers(ctx context.Context, guildID string, battleTags map[string]string) error
+
+	// GetUserBattleTag returns the BattleTag of a user in a guild, or an empty string if the user isn't registered
+	GetUserBattleTag(ctx context.Context, guildID, userID string) (string, error)
+
+	// GetUserRegistration returns the registration of a user in a guild, or nil if the user isn't registered
+	GetUserRegistration(ctx context.Context, guildID, userID string) (*UserRegistration, error)
+
+	// SetUserPreferences saves the preferred platform and roles of a registered user, returns ErrNotFound if the user isn't registered
+	SetUserPreferences(ctx context.Context, guildID, userID, platform string, roles []string) error
+
+	// GetUserBattleTags returns the distinct BattleTags a user linked across all guilds
+	GetUserBattleTags(ctx context.Context, userID string) ([]string, error)
+
+	// UnregisterUser removes the link of a user in a guild, returns ErrNotFound if the user isn't registered
+	UnregisterUser(ctx context.Context, guildID, userID string) error
+
+	// GetGuildRegistrations returns every registration of a guild
+	GetGuildRegistrations(ctx context.Context, guildID string) ([]UserRegistration, error)
+
+	// GetUserStats returns the number of registrations across all guilds
+	GetUserStats(ctx context.Context) (int64, error)
+
+	// DeleteGuildRegistrations removes every registration of a guild and returns how many were removed
+	DeleteGuildRegistrations(ctx context.Context, guildID string) (int64, error)
+}
+
+// LookupRegistration returns the registration of a user in a guild, falling back to their global account link
+// It returns nil if the user has neither
+func LookupRegistration(ctx context.Context, store RegistrationStore, guildID, userID string) (*UserRegistration, error) {
+	if guildID != GlobalGuildID {
+		registration, err := store.GetUserRegistration(ctx, guildID, userID)
+		if err != nil || registration != nil {
+			return registration, err
+		}
+	}
+
+	return store.GetUserRegistration(ctx, GlobalGuildID, userID)
+}
+
+// SettingsStore manages the per-guild configuration of the bot
+type SettingsStore interface {
+	// GetGuildSettings returns the settings of a guild, or the default settings if none were saved
+	GetGuildSettings(ctx context.Context, guildID string) (*GuildSettings, error)
+
+	// SaveGuildSettings creates or updates the settings of a guild
+	SaveGuildSettings(ctx context.Context, settings *GuildSettings) error
+
+	// GetRemovedGuilds returns the settings of the guilds the bot was removed from before a time
+	GetRemovedGuilds(ctx context.Context, before time.Time) ([]GuildSettings, error)
+}
+
+// PermissionStore manages the roles and channels guild admins restrict commands to
+type PermissionStore interface {
+	// AddCommandRestriction restricts a command of a guild to a role or a channel, adding an existing restriction does nothing
+	AddCommandRestriction(ctx context.Context, guildID, command, kind, targetID string) error
+
+	// RemoveCommandRestriction removes a restriction, returns ErrNotFound if the command wasn't restricted to the target
+	RemoveCommandRestriction(ctx context.Context, guildID, command, kind, targetID string) error
+
+	// GetCommandRestrictions returns the restrictions of a command in a guild
+	GetCommandRestrictions(ctx context.Context, guildID, command string) ([]CommandRestriction, error)
+
+	// GetGuildRestrictions returns every restriction of a guild, ordered by command
+	GetGuildRestrictions(ctx context.Context, guildID string) ([]CommandRestriction, error)
+
+	// ClearCommandRestrictions removes every restriction of a command in a guild and returns how many were removed
+	ClearCommandRestrictions(ctx context.Context, guildID, command string) (int64, error)
+}
+
+// FeatureStore manages the commands enabled or disabled per guild
+type FeatureStore interface {
+	// SetCommandToggle enables or disables a command in a guild, GlobalGuildID sets the default of every guild
+	SetCommandToggle(ctx context.Context, guildID, command string, enabled bool) error
+
+	// RemoveCommandToggle removes the toggle of a command in a guild, returns ErrNotFound if there is none
+	RemoveCommandToggle(ctx context.Context, guildID, command string) error
+
+	// GetCommandToggles returns the toggles of every guild
+	GetCommandToggles(ctx context.Context) ([]CommandToggle, error)
+}
+
+// BackupStore writes snapshots of the database
+type BackupStore interface {
+	// BackupToDir writes a timestamped backup in dir and returns its path
+	BackupToDir(dir string) (string, error)
+}
+
+// Pinger checks that the database is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Store groups every repository used by the bot commands
+type Store interface {
+	RegistrationStore
+	SettingsStore
+	PermissionStore
+	FeatureStore
+}
+
+// ensure Database implements Store, BackupStore and Pinger
+var (
+	_ Store       = (*Database)(nil)
+	_ BackupStore = (*Database)(nil)
+	_ Pinger      = (*Database)(nil)
+)
@@ -0,0 +1,510 @@
+// Package storetest checks that an implementation of database.Store behaves like the others
+// The suite only uses IDs of its own, so it can run against a database shared with other tests
+package storetest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/borisjacquot/juno/internal/database"
+)
+
+// Run runs the conformance suite against the store returned by newStore, which is called once per test
+func Run(t *testing.T, newStore func(t *testing.T) database.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, ctx context.Context, store database.Store, ids *ids)
+	}{
+		{"RegisterUser", testRegisterUser},
+		{"RegisterUserReplaces", testRegisterUserReplaces},
+		{"RegisterUsers", testRegisterUsers},
+		{"SetUserPreferences", testSetUserPreferences},
+		{"GetUserBattleTags", testGetUserBattleTags},
+		{"UnregisterUser", testUnregisterUser},
+		{"RegisterAfterUnregister", testRegisterAfterUnregister},
+		{"GuildRegistrations", testGuildRegistrations},
+		{"GetUserStats", testGetUserStats},
+		{"LookupRegistration", testLookupRegistration},
+		{"GuildSettings", testGuildSettings},
+		{"GetRemovedGuilds", testGetRemovedGuilds},
+		{"CommandRestrictions", testCommandRestrictions},
+		{"CommandToggles", testCommandToggles},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, context.Background(), newStore(t), newIDs(t))
+		})
+	}
+}
+
+// ids generates guild and user IDs no other test uses
+type ids struct {
+	prefix string
+}
+
+func newIDs(t *testing.T) *ids {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatalf("failed to generate IDs: %v", err)
+	}
+	return &ids{prefix: hex.EncodeToString(suffix)}
+}
+
+func (i *ids) guild(name string) string {
+	return "guild-" + name + "-" + i.prefix
+}
+
+func (i *ids) user(name string) string {
+	return "user-" + name + "-" + i.prefix
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func testRegisterUser(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild, user := ids.guild("a"), ids.user("a")
+
+	battleTag, err := store.GetUserBattleTag(ctx, guild, user)
+	must(t, err)
+	if battleTag != "" {
+		t.Errorf("BattleTag of unregistered user = %q, want empty", battleTag)
+	}
+	registration, err := store.GetUserRegistration(ctx, guild, user)
+	must(t, err)
+	if registration != nil {
+		t.Errorf("registration of unregistered user = %+v, want nil", registration)
+	}
+
+	must(t, store.RegisterUser(ctx, guild, user, "Player#1234"))
+
+	battleTag, err = store.GetUserBattleTag(ctx, guild, user)
+	must(t, err)
+	if battleTag != "Player#1234" {
+		t.Errorf("BattleTag = %q, want Player#1234", battleTag)
+	}
+	registration, err = store.GetUserRegistration(ctx, guild, user)
+	must(t, err)
+	if registration == nil {
+		t.Fatal("registration = nil, want the registration")
+	}
+	if registration.GuildID != guild || registration.UserID != user || registration.BattleTag != "Player#1234" {
+		t.Errorf("registration = %+v, want guild %s, user %s and Player#1234", registration, guild, user)
+	}
+	if registration.ID == 0 || registration.CreatedAt.IsZero() {
+		t.Errorf("registration has no ID or creation date: %+v", registration)
+	}
+
+	// the registration only exists in its guild
+	battleTag, err = store.GetUserBattleTag(ctx, ids.guild("b"), user)
+	must(t, err)
+	if battleTag != "" {
+		t.Errorf("BattleTag in another guild = %q, want empty", battleTag)
+	}
+}
+
+func testRegisterUserReplaces(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild, user := ids.guild("a"), ids.user("a")
+
+	must(t, store.RegisterUser(ctx, guild, user, "Player#1234"))
+	must(t, store.SetUserPreferences(ctx, guild, user, database.PlatformConsole, []string{database.RoleTank}))
+	must(t, store.RegisterUser(ctx, guild, user, "Other#5678"))
+
+	registration, err := store.GetUserRegistration(ctx, guild, user)
+	must(t, err)
+	if registration == nil || registration.BattleTag != "Other#5678" {
+		t.Fatalf("registration = %+v, want Other#5678", registration)
+	}
+	// the preferences are kept when the BattleTag changes
+	if registration.Platform != database.PlatformConsole {
+		t.Errorf("platform = %q, want %q", registration.Platform, database.PlatformConsole)
+	}
+
+	registrations, err := store.GetGuildRegistrations(ctx, guild)
+	must(t, err)
+	if len(registrations) != 1 {
+		t.Errorf("guild has %d registrations, want 1", len(registrations))
+	}
+}
+
+func testRegisterUsers(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild := ids.guild("a")
+
+	must(t, store.RegisterUser(ctx, guild, ids.user("a"), "Player#1234"))
+	must(t, store.RegisterUsers(ctx, guild, map[string]string{
+		ids.user("a"): "Other#5678",
+		ids.user("b"): "Second#2222",
+	}))
+
+	registrations, err := store.GetGuildRegistrations(ctx, guild)
+	must(t, err)
+	battleTags := map[string]string{}
+	for _, registration := range registrations {
+		battleTags[registration.UserID] = registration.BattleTag
+	}
+	want := map[string]string{ids.user("a"): "Other#5678", ids.user("b"): "Second#2222"}
+	if !maps.Equal(battleTags, want) {
+		t.Errorf("guild registrations = %v, want %v", battleTags, want)
+	}
+
+	// an empty import changes nothing
+	must(t, store.RegisterUsers(ctx, guild, map[string]string{}))
+	registrations, err = store.GetGuildRegistrations(ctx, guild)
+	must(t, err)
+	if len(registrations) != 2 {
+		t.Errorf("guild has %d registrations after an empty import, want 2", len(registrations))
+	}
+}
+
+func testSetUserPreferences(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild, user := ids.guild("a"), ids.user("a")
+
+	err := store.SetUserPreferences(ctx, guild, user, database.PlatformPC, nil)
+	if !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("preferences of unregistered user: error = %v, want ErrNotFound", err)
+	}
+
+	must(t, store.RegisterUser(ctx, guild, user, "Player#1234"))
+	must(t, store.SetUserPreferences(ctx, guild, user, database.PlatformConsole, []string{database.RoleTank, database.RoleSupport}))
+
+	registration, err := store.GetUserRegistration(ctx, guild, user)
+	must(t, err)
+	if registration.Platform != database.PlatformConsole {
+		t.Errorf("platform = %q, want %q", registration.Platform, database.PlatformConsole)
+	}
+	if roles := registration.RoleList(); !slices.Equal(roles, []string{database.RoleTank, database.RoleSupport}) {
+		t.Errorf("roles = %v, want [tank support]", roles)
+	}
+
+	// clearing the preferences
+	must(t, store.SetUserPreferences(ctx, guild, user, "", nil))
+	registration, err = store.GetUserRegistration(ctx, guild, user)
+	must(t, err)
+	if registration.Platform != "" || registration.RoleList() != nil {
+		t.Errorf("preferences = %q %v, want none", registration.Platform, registration.RoleList())
+	}
+}
+
+func testGetUserBattleTags(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	user := ids.user("a")
+
+	battleTags, err := store.GetUserBattleTags(ctx, user)
+	must(t, err)
+	if len(battleTags) != 0 {
+		t.Errorf("BattleTags of unregistered user = %v, want none", battleTags)
+	}
+
+	must(t, store.RegisterUser(ctx, ids.guild("a"), user, "Zed#1234"))
+	must(t, store.RegisterUser(ctx, ids.guild("b"), user, "Alpha#1234"))
+	must(t, store.RegisterUser(ctx, ids.guild("c"), user, "Zed#1234"))
+	must(t, store.RegisterUser(ctx, database.GlobalGuildID, user, "Alpha#1234"))
+	must(t, store.RegisterUser(ctx, ids.guild("a"), ids.user("b"), "Other#1234"))
+
+	battleTags, err = store.GetUserBattleTags(ctx, user)
+	must(t, err)
+	if !slices.Equal(battleTags, []string{"Alpha#1234", "Zed#1234"}) {
+		t.Errorf("BattleTags = %v, want [Alpha#1234 Zed#1234]", battleTags)
+	}
+}
+
+func testUnregisterUser(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild, user := ids.guild("a"), ids.user("a")
+
+	if err := store.UnregisterUser(ctx, guild, user); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("unregistering unregistered user: error = %v, want ErrNotFound", err)
+	}
+
+	must(t, store.RegisterUser(ctx, guild, user, "Player#1234"))
+	must(t, store.RegisterUser(ctx, ids.guild("b"), user, "Player#1234"))
+	must(t, store.UnregisterUser(ctx, guild, user))
+
+	registration, err := store.GetUserRegistration(ctx, guild, user)
+	must(t, err)
+	if registration != nil {
+		t.Errorf("registration after unregistering = %+v, want nil", registration)
+	}
+	// the registrations in the other guilds are kept
+	battleTag, err := store.GetUserBattleTag(ctx, ids.guild("b"), user)
+	must(t, err)
+	if battleTag != "Player#1234" {
+		t.Errorf("BattleTag in another guild = %q, want Player#1234", battleTag)
+	}
+
+	if err := store.UnregisterUser(ctx, guild, user); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("unregistering twice: error = %v, want ErrNotFound", err)
+	}
+}
+
+func testRegisterAfterUnregister(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild, user := ids.guild("a"), ids.user("a")
+
+	must(t, store.RegisterUser(ctx, guild, user, "Player#1234"))
+	must(t, store.SetUserPreferences(ctx, guild, user, database.PlatformConsole, []string{database.RoleTank}))
+	must(t, store.UnregisterUser(ctx, guild, user))
+	must(t, store.RegisterUser(ctx, guild, user, "Other#5678"))
+
+	registration, err := store.GetUserRegistration(ctx, guild, user)
+	must(t, err)
+	if registration == nil || registration.BattleTag != "Other#5678" {
+		t.Fatalf("registration after registering again = %+v, want Other#5678", registration)
+	}
+	// it's a new registration, the preferences of the previous one are gone
+	if registration.Platform != "" || registration.Roles != "" {
+		t.Errorf("preferences after registering again = %q and %q, want none", registration.Platform, registration.Roles)
+	}
+
+	must(t, store.UnregisterUser(ctx, guild, user))
+	must(t, store.RegisterUsers(ctx, guild, map[string]string{user: "Third#9999"}))
+	battleTag, err := store.GetUserBattleTag(ctx, guild, user)
+	must(t, err)
+	if battleTag != "Third#9999" {
+		t.Errorf("BattleTag after importing again = %q, want Third#9999", battleTag)
+	}
+}
+
+func testGuildRegistrations(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild := ids.guild("a")
+
+	registrations, err := store.GetGuildRegistrations(ctx, guild)
+	must(t, err)
+	if len(registrations) != 0 {
+		t.Errorf("registrations of empty guild = %v, want none", registrations)
+	}
+
+	must(t, store.RegisterUser(ctx, guild, ids.user("a"), "First#1234"))
+	must(t, store.RegisterUser(ctx, guild, ids.user("b"), "Second#1234"))
+	must(t, store.RegisterUser(ctx, ids.guild("b"), ids.user("c"), "Third#1234"))
+	// unregistered users are neither listed nor counted as removed
+	must(t, store.RegisterUser(ctx, guild, ids.user("d"), "Gone#1234"))
+	must(t, store.UnregisterUser(ctx, guild, ids.user("d")))
+
+	registrations, err = store.GetGuildRegistrations(ctx, guild)
+	must(t, err)
+	var battleTags []string
+	for _, registration := range registrations {
+		battleTags = append(battleTags, registration.BattleTag)
+	}
+	slices.Sort(battleTags)
+	if !slices.Equal(battleTags, []string{"First#1234", "Second#1234"}) {
+		t.Errorf("guild BattleTags = %v, want [First#1234 Second#1234]", battleTags)
+	}
+
+	removed, err := store.DeleteGuildRegistrations(ctx, guild)
+	must(t, err)
+	if removed != 2 {
+		t.Errorf("removed %d registrations, want 2", removed)
+	}
+	registrations, err = store.GetGuildRegistrations(ctx, guild)
+	must(t, err)
+	if len(registrations) != 0 {
+		t.Errorf("registrations after deleting = %v, want none", registrations)
+	}
+	registrations, err = store.GetGuildRegistrations(ctx, ids.guild("b"))
+	must(t, err)
+	if len(registrations) != 1 {
+		t.Errorf("other guild has %d registrations, want 1", len(registrations))
+	}
+
+	// the users can register again once their registrations are deleted
+	must(t, store.RegisterUser(ctx, guild, ids.user("d"), "Back#1234"))
+	battleTag, err := store.GetUserBattleTag(ctx, guild, ids.user("d"))
+	must(t, err)
+	if battleTag != "Back#1234" {
+		t.Errorf("BattleTag after registering again = %q, want Back#1234", battleTag)
+	}
+}
+
+func testGetUserStats(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	before, err := store.GetUserStats(ctx)
+	must(t, err)
+
+	must(t, store.RegisterUser(ctx, ids.guild("a"), ids.user("a"), "Player#1234"))
+	must(t, store.RegisterUser(ctx, ids.guild("b"), ids.user("a"), "Player#1234"))
+	must(t, store.RegisterUser(ctx, ids.guild("a"), ids.user("a"), "Other#1234"))
+
+	after, err := store.GetUserStats(ctx)
+	must(t, err)
+	if after-before != 2 {
+		t.Errorf("registrations went from %d to %d, want 2 more", before, after)
+	}
+}
+
+func testLookupRegistration(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild, user := ids.guild("a"), ids.user("a")
+
+	registration, err := database.LookupRegistration(ctx, store, guild, user)
+	must(t, err)
+	if registration != nil {
+		t.Errorf("lookup of unregistered user = %+v, want nil", registration)
+	}
+
+	// the global account link is used where the user has no registration of their own
+	must(t, store.RegisterUser(ctx, database.GlobalGuildID, user, "Global#1234"))
+	registration, err = database.LookupRegistration(ctx, store, guild, user)
+	must(t, err)
+	if registration == nil || registration.BattleTag != "Global#1234" {
+		t.Errorf("lookup = %+v, want Global#1234", registration)
+	}
+
+	must(t, store.RegisterUser(ctx, guild, user, "Guild#1234"))
+	registration, err = database.LookupRegistration(ctx, store, guild, user)
+	must(t, err)
+	if registration == nil || registration.BattleTag != "Guild#1234" {
+		t.Errorf("lookup = %+v, want Guild#1234", registration)
+	}
+}
+
+func testGuildSettings(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild := ids.guild("a")
+
+	settings, err := store.GetGuildSettings(ctx, guild)
+	must(t, err)
+	if settings.ID != 0 || settings.GuildID != guild {
+		t.Errorf("settings of unconfigured guild = %+v, want unsaved defaults", settings)
+	}
+
+	settings.WelcomeMessage = "Hello"
+	must(t, store.SaveGuildSettings(ctx, settings))
+
+	saved, err := store.GetGuildSettings(ctx, guild)
+	must(t, err)
+	if saved.ID == 0 || saved.WelcomeMessage != "Hello" {
+		t.Errorf("saved settings = %+v, want the welcome message", saved)
+	}
+
+	// saving the settings read from the store updates them
+	saved.WelcomeMessage = ""
+	must(t, store.SaveGuildSettings(ctx, saved))
+	updated, err := store.GetGuildSettings(ctx, guild)
+	must(t, err)
+	if updated.ID != saved.ID || updated.WelcomeMessage != "" {
+		t.Errorf("updated settings = %+v, want ID %d and no welcome message", updated, saved.ID)
+	}
+}
+
+func testGetRemovedGuilds(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	removedAt := time.Now().Add(-2 * time.Hour)
+
+	old := database.DefaultGuildSettings(ids.guild("old"))
+	old.RemovedAt = &removedAt
+	must(t, store.SaveGuildSettings(ctx, old))
+	must(t, store.SaveGuildSettings(ctx, database.DefaultGuildSettings(ids.guild("member"))))
+
+	guilds, err := store.GetRemovedGuilds(ctx, time.Now().Add(-time.Hour))
+	must(t, err)
+	if !containsGuild(guilds, ids.guild("old")) {
+		t.Errorf("removed guilds = %v, want %s", guilds, ids.guild("old"))
+	}
+	if containsGuild(guilds, ids.guild("member")) {
+		t.Errorf("removed guilds = %v, want no %s", guilds, ids.guild("member"))
+	}
+
+	guilds, err = store.GetRemovedGuilds(ctx, time.Now().Add(-3*time.Hour))
+	must(t, err)
+	if containsGuild(guilds, ids.guild("old")) {
+		t.Errorf("guilds removed 3 hours ago = %v, want no %s", guilds, ids.guild("old"))
+	}
+}
+
+func containsGuild(guilds []database.GuildSettings, guildID string) bool {
+	return slices.ContainsFunc(guilds, func(settings database.GuildSettings) bool {
+		return settings.GuildID == guildID
+	})
+}
+
+func testCommandRestrictions(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild := ids.guild("a")
+
+	must(t, store.AddCommandRestriction(ctx, guild, "profile", database.RestrictionRole, "role-1"))
+	must(t, store.AddCommandRestriction(ctx, guild, "profile", database.RestrictionChannel, "channel-1"))
+	must(t, store.AddCommandRestriction(ctx, guild, "hero", database.RestrictionRole, "role-1"))
+	// adding an existing restriction does nothing
+	must(t, store.AddCommandRestriction(ctx, guild, "profile", database.RestrictionRole, "role-1"))
+
+	restrictions, err := store.GetCommandRestrictions(ctx, guild, "profile")
+	must(t, err)
+	if len(restrictions) != 2 {
+		t.Errorf("profile has %d restrictions, want 2", len(restrictions))
+	}
+
+	restrictions, err = store.GetGuildRestrictions(ctx, guild)
+	must(t, err)
+	var commands []string
+	for _, restriction := range restrictions {
+		commands = append(commands, restriction.Command)
+	}
+	if !slices.Equal(commands, []string{"hero", "profile", "profile"}) {
+		t.Errorf("guild restrictions are for %v, want [hero profile profile]", commands)
+	}
+
+	must(t, store.RemoveCommandRestriction(ctx, guild, "profile", database.RestrictionRole, "role-1"))
+	err = store.RemoveCommandRestriction(ctx, guild, "profile", database.RestrictionRole, "role-1")
+	if !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("removing a missing restriction: error = %v, want ErrNotFound", err)
+	}
+
+	cleared, err := store.ClearCommandRestrictions(ctx, guild, "profile")
+	must(t, err)
+	if cleared != 1 {
+		t.Errorf("cleared %d restrictions, want 1", cleared)
+	}
+	restrictions, err = store.GetCommandRestrictions(ctx, guild, "profile")
+	must(t, err)
+	if len(restrictions) != 0 {
+		t.Errorf("profile restrictions after clearing = %v, want none", restrictions)
+	}
+}
+
+func testCommandToggles(t *testing.T, ctx context.Context, store database.Store, ids *ids) {
+	guild := ids.guild("a")
+	command := "cmd-" + ids.prefix
+
+	must(t, store.SetCommandToggle(ctx, guild, command, false))
+	must(t, store.SetCommandToggle(ctx, ids.guild("b"), command, true))
+	// setting a toggle again replaces it
+	must(t, store.SetCommandToggle(ctx, guild, command, true))
+
+	toggles := ownToggles(t, ctx, store, command)
+	if len(toggles) != 2 {
+		t.Fatalf("toggles = %+v, want 2", toggles)
+	}
+	for _, toggle := range toggles {
+		if !toggle.Enabled {
+			t.Errorf("toggle of %s is disabled, want enabled", toggle.GuildID)
+		}
+	}
+
+	must(t, store.RemoveCommandToggle(ctx, guild, command))
+	if err := store.RemoveCommandToggle(ctx, guild, command); !errors.Is(err, database.ErrNotFound) {
+		t.Errorf("removing a missing toggle: error = %v, want ErrNotFound", err)
+	}
+	if toggles := ownToggles(t, ctx, store, command); len(toggles) != 1 {
+		t.Errorf("toggles after removing = %+v, want 1", toggles)
+	}
+}
+
+// ownToggles returns the toggles of a command, leaving out the ones of other tests
+func ownToggles(t *testing.T, ctx context.Context, store database.Store, command string) []database.CommandToggle {
+	t.Helper()
+
+	toggles, err := store.GetCommandToggles(ctx)
+	must(t, err)
+
+	var own []database.CommandToggle
+	for _, toggle := range toggles {
+		if toggle.Command == command {
+			own = append(own, toggle)
+		}
+	}
+	return own
+}
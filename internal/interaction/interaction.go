@@ -0,0 +1,34 @@
+// Package interaction provides helpers for Discord interactions received in guilds and in direct messages
+package interaction
+
+import (
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// snowflakeRegex matches the IDs of Discord users, guilds and channels
+var snowflakeRegex = regexp.MustCompile(`^[0-9]{17,20}$`)
+
+// User returns the user who triggered an interaction
+// In guilds Discord sends the member, in direct messages the user, so Member must not be used directly
+// It returns an empty user if the interaction carries neither
+func User(i *discordgo.Interaction) *discordgo.User {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User
+	}
+	if i.User != nil {
+		return i.User
+	}
+	return &discordgo.User{}
+}
+
+// InDM reports whether an interaction was triggered in direct messages
+func InDM(i *discordgo.Interaction) bool {
+	return i.GuildID == ""
+}
+
+// IsSnowflake reports whether id looks like a Discord ID
+func IsSnowflake(id string) bool {
+	return snowflakeRegex.MatchString(id)
+}
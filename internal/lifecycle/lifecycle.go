@@ -0,0 +1,114 @@
+// Package lifecycle tracks the work in progress so the bot can stop without interrupting it
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Group tracks the interactions being handled and the jobs running in the background
+// Once Shutdown is called no new work is accepted, the work in progress gets until the deadline to finish
+type Group struct {
+	mu       sync.Mutex
+	stopping bool
+	running  int
+	wg       sync.WaitGroup
+
+	// ctx is cancelled when the deadline of the shutdown expires, to abort the work still running
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	logger *log.Logger
+}
+
+// NewGroup creates an empty group
+func NewGroup(logger *log.Logger) *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{
+		ctx:    ctx,
+		cancel: cancel,
+		logger: logger,
+	}
+}
+
+// Enter registers a unit of work, done must be called once it's finished
+// The returned context is cancelled if the work is still running when the shutdown deadline expires
+// ok is false when the group is shutting down, the work must then be refused
+func (g *Group) Enter() (ctx context.Context, done func(), ok bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.stopping {
+		return nil, nil, false
+	}
+
+	g.running++
+	g.wg.Add(1)
+
+	var once sync.Once
+	return g.ctx, func() {
+		once.Do(func() {
+			g.mu.Lock()
+			g.running--
+			g.mu.Unlock()
+			g.wg.Done()
+		})
+	}, true
+}
+
+// Go runs a background job tracked by the group, it returns false if the group is shutting down
+func (g *Group) Go(name string, job func(ctx context.Context)) bool {
+	ctx, done, ok := g.Enter()
+	if !ok {
+		g.logger.WithField("job", name).Debug("Shutting down, background job not started")
+		return false
+	}
+
+	go func() {
+		defer done()
+		job(ctx)
+	}()
+	return true
+}
+
+// Stopping reports whether Shutdown was called
+func (g *Group) Stopping() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stopping
+}
+
+// Running returns the number of interactions and jobs in progress
+func (g *Group) Running() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.running
+}
+
+// Shutdown stops accepting work and waits for the work in progress, for timeout at most
+// When the deadline expires the context of the remaining work is cancelled and an error is returned
+func (g *Group) Shutdown(timeout time.Duration) error {
+	g.mu.Lock()
+	g.stopping = true
+	g.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		g.cancel()
+		return nil
+	case <-time.After(timeout):
+		running := g.Running()
+		g.cancel()
+		return fmt.Errorf("%d interactions or jobs still running after %s, they were cancelled", running, timeout)
+	}
+}
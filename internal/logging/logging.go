@@ -0,0 +1,92 @@
+// Package logging configures the logger of the bot and ties log lines to the interaction that produced them
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CorrelationField is the field holding the correlation ID in log lines
+const CorrelationField = "correlation_id"
+
+type correlationKey struct{}
+
+// New creates a logger writing to stdout with the given level and format (text or json)
+// BattleTags and user IDs are redacted from every line when redact is set, hashed with redactKey
+// A random key is used when redactKey is empty, the hashes then only match within a run
+func New(level, format string, redact bool, redactKey string) *log.Logger {
+	logger := log.New()
+	logger.SetOutput(os.Stdout)
+
+	switch format {
+	case "json":
+		logger.SetFormatter(&log.JSONFormatter{
+			TimestampFormat: "2006-01-02T15:04:05.000Z07:00",
+		})
+	default:
+		logger.SetFormatter(&log.TextFormatter{
+			FullTimestamp:   true,
+			TimestampFormat: "2006-01-02 15:04:05",
+		})
+	}
+
+	switch level {
+	case "debug":
+		logger.SetLevel(log.DebugLevel)
+	case "warn":
+		logger.SetLevel(log.WarnLevel)
+	case "error":
+		logger.SetLevel(log.ErrorLevel)
+	default:
+		logger.SetLevel(log.InfoLevel)
+	}
+
+	logger.AddHook(correlationHook{})
+	if redact {
+		logger.AddHook(newRedactionHook(redactKey))
+	}
+
+	return logger
+}
+
+// WithCorrelationID returns a context carrying a correlation ID
+// Lines logged with logger.WithContext(ctx) get the ID in their correlation_id field
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID of a context, or an empty string if it has none
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// NewCorrelationID returns a random correlation ID
+func NewCorrelationID() string {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(id)
+}
+
+// correlationHook adds the correlation ID of the context of an entry to its fields
+type correlationHook struct{}
+
+func (correlationHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (correlationHook) Fire(entry *log.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if id := CorrelationID(entry.Context); id != "" {
+		entry.Data[CorrelationField] = id
+	}
+	return nil
+}
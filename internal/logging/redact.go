@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// redactedFields hold user IDs, usernames or BattleTags, their values are always redacted
+var redactedFields = map[string]bool{
+	"user_id":   true,
+	"user":      true,
+	"requester": true,
+	"target":    true,
+	"player":    true,
+}
+
+// droppedFields hold what users typed (command options, component state), it can't be redacted reliably
+var droppedFields = map[string]bool{
+	"options": true,
+	"state":   true,
+}
+
+// battleTagPattern matches BattleTags in display (Player#1234) and API (Player-1234) formats, in URLs too
+var battleTagPattern = regexp.MustCompile(`[\p{L}\p{N}]{3,12}[#-][0-9]{4,5}\b`)
+
+// redactionHook redacts the fields holding personal data and the BattleTags found in the message and string fields
+// Values are replaced by a keyed hash, the same value always gives the same hash so the lines of a user can still be
+// followed, without the key the hash can't be matched with a known BattleTag or user ID
+type redactionHook struct {
+	key []byte
+}
+
+func newRedactionHook(key string) redactionHook {
+	if key != "" {
+		return redactionHook{key: []byte(key)}
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		// crypto/rand never fails on supported platforms
+		panic(fmt.Sprintf("failed to generate redaction key: %v", err))
+	}
+	return redactionHook{key: random}
+}
+
+func (redactionHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h redactionHook) Fire(entry *log.Entry) error {
+	entry.Message = h.redactBattleTags(entry.Message)
+
+	for key, value := range entry.Data {
+		switch {
+		case droppedFields[key]:
+			delete(entry.Data, key)
+		case key == "battletag":
+			entry.Data[key] = h.redactBattleTag(fmt.Sprint(value))
+		case redactedFields[key]:
+			entry.Data[key] = h.redact(fmt.Sprint(value))
+		default:
+			switch v := value.(type) {
+			case string:
+				entry.Data[key] = h.redactBattleTags(v)
+			case error:
+				entry.Data[key] = h.redactBattleTags(v.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// redact replaces a value by a short keyed hash
+func (h redactionHook) redact(value string) string {
+	if value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, h.key)
+	mac.Write([]byte(value))
+	return "redacted:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+func (h redactionHook) redactBattleTags(s string) string {
+	return battleTagPattern.ReplaceAllStringFunc(s, h.redactBattleTag)
+}
+
+// redactBattleTag redacts a BattleTag, both formats of the same BattleTag give the same hash
+func (h redactionHook) redactBattleTag(battleTag string) string {
+	if i := strings.LastIndex(battleTag, "-"); i >= 0 {
+		battleTag = battleTag[:i] + "#" + battleTag[i+1:]
+	}
+	return h.redact(battleTag)
+}
@@ -0,0 +1,126 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/borisjacquot/juno/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	battleTag = "Player#1234"
+	userID    = "200000000000000001"
+)
+
+// logLine logs message with fields through a JSON logger and returns the decoded line
+func logLine(t *testing.T, redact bool, key, message string, fields log.Fields) map[string]any {
+	t.Helper()
+
+	var buf bytes.Buffer
+	logger := logging.New("info", "json", redact, key)
+	logger.SetOutput(&buf)
+	logger.WithFields(fields).Info(message)
+
+	line := map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("failed to decode log line %q: %v", buf.String(), err)
+	}
+	return line
+}
+
+func TestRedaction(t *testing.T) {
+	tests := []struct {
+		name        string
+		message     string
+		fields      log.Fields
+		wantDropped []string
+	}{
+		{
+			name:    "message",
+			message: "Fetching profile of " + battleTag,
+		},
+		{
+			name:    "API format in a URL",
+			message: "GET https://overfast-api.tekrop.fr/players/Player-1234/summary",
+		},
+		{
+			name:   "battletag field",
+			fields: log.Fields{"battletag": battleTag},
+		},
+		{
+			name:   "user fields",
+			fields: log.Fields{"user_id": userID, "user": "tester", "requester": userID, "target": userID, "player": battleTag},
+		},
+		{
+			name:   "error field",
+			fields: log.Fields{"error": errors.New("player " + battleTag + " not found")},
+		},
+		{
+			name:        "typed options",
+			fields:      log.Fields{"options": "battletag:" + battleTag, "state": userID},
+			wantDropped: []string{"options", "state"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := logLine(t, true, "key", tt.message, tt.fields)
+
+			encoded, _ := json.Marshal(line)
+			for _, raw := range []string{battleTag, "Player-1234", userID, "tester"} {
+				if strings.Contains(string(encoded), raw) {
+					t.Errorf("line %s leaks %q", encoded, raw)
+				}
+			}
+			for _, field := range tt.wantDropped {
+				if _, ok := line[field]; ok {
+					t.Errorf("line %s has field %s, want it dropped", encoded, field)
+				}
+			}
+			if len(tt.wantDropped) == 0 && !strings.Contains(string(encoded), "redacted:") {
+				t.Errorf("line %s has no redacted value", encoded)
+			}
+		})
+	}
+}
+
+func TestRedactionKey(t *testing.T) {
+	hash := func(key, value string) string {
+		return logLine(t, true, key, "", log.Fields{"battletag": value})["battletag"].(string)
+	}
+
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{"same key", hash("key", battleTag), hash("key", battleTag), true},
+		{"both formats", hash("key", battleTag), hash("key", "Player-1234"), true},
+		{"other key", hash("key", battleTag), hash("other", battleTag), false},
+		{"random keys", hash("", battleTag), hash("", battleTag), false},
+		{"other value", hash("key", battleTag), hash("key", "Other#5678"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.HasPrefix(tt.a, "redacted:") || !strings.HasPrefix(tt.b, "redacted:") {
+				t.Fatalf("hashes %q and %q aren't redacted", tt.a, tt.b)
+			}
+			if (tt.a == tt.b) != tt.equal {
+				t.Errorf("hashes %q and %q: equal = %t, want %t", tt.a, tt.b, tt.a == tt.b, tt.equal)
+			}
+		})
+	}
+}
+
+func TestNoRedaction(t *testing.T) {
+	line := logLine(t, false, "", "Fetching profile of "+battleTag, log.Fields{"user_id": userID, "options": "battletag"})
+
+	if line["msg"] != "Fetching profile of "+battleTag || line["user_id"] != userID || line["options"] != "battletag" {
+		t.Errorf("line = %v, want the values untouched", line)
+	}
+}
@@ -0,0 +1,154 @@
+// Package metrics records the activity of the bot in a Prometheus registry
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "juno"
+
+// Metrics holds the collectors of the bot
+// It implements commands.MetricsRecorder, overwatch.Observer and bot.GatewayRecorder
+type Metrics struct {
+	registry *prometheus.Registry
+
+	commands         *prometheus.CounterVec
+	commandFailures  *prometheus.CounterVec
+	commandDuration  *prometheus.HistogramVec
+	overwatchLatency *prometheus.HistogramVec
+	overwatchStatus  *prometheus.CounterVec
+	cacheRequests    *prometheus.CounterVec
+	reconnects       *prometheus.CounterVec
+	shards           prometheus.Gauge
+	shardConnected   *prometheus.GaugeVec
+}
+
+// New creates the collectors in a new registry, with the Go runtime and process collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		commands: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commands_total",
+			Help:      "Number of executed commands, by command path.",
+		}, []string{"command"}),
+		commandFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "command_failures_total",
+			Help:      "Number of commands that returned an error or panicked, by command path.",
+		}, []string{"command"}),
+		commandDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "command_duration_seconds",
+			Help:      "Duration of the executions of commands, by command path.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"command"}),
+		overwatchLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "overwatch_request_duration_seconds",
+			Help:      "Latency of the requests to the Overwatch API, by endpoint.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		}, []string{"endpoint"}),
+		overwatchStatus: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "overwatch_responses_total",
+			Help:      "Number of responses of the Overwatch API, by endpoint and status code (error when the request failed).",
+		}, []string{"endpoint", "status"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Number of cache lookups, by cache and result (hit or miss).",
+		}, []string{"cache", "result"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "gateway_reconnects_total",
+			Help:      "Number of times the connection to the Discord gateway was established again after the first one, by shard.",
+		}, []string{"shard"}),
+		shards: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "gateway_shards",
+			Help:      "Number of shards the connection to the Discord gateway is split into.",
+		}),
+		shardConnected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "gateway_shard_connected",
+			Help:      "Whether the shard is connected to the Discord gateway (1) or not (0), by shard.",
+		}, []string{"shard"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.commands,
+		m.commandFailures,
+		m.commandDuration,
+		m.overwatchLatency,
+		m.overwatchStatus,
+		m.cacheRequests,
+		m.reconnects,
+		m.shards,
+		m.shardConnected,
+	)
+
+	return m
+}
+
+// Registry returns the registry holding the collectors
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// ObserveCommand records the execution of a command
+func (m *Metrics) ObserveCommand(path string, duration time.Duration, err error) {
+	m.commands.WithLabelValues(path).Inc()
+	m.commandDuration.WithLabelValues(path).Observe(duration.Seconds())
+	if err != nil {
+		m.commandFailures.WithLabelValues(path).Inc()
+	}
+}
+
+// ObserveRequest records a request to the Overwatch API, status is 0 when no response was received
+func (m *Metrics) ObserveRequest(endpoint string, status int, duration time.Duration) {
+	m.overwatchLatency.WithLabelValues(endpoint).Observe(duration.Seconds())
+
+	label := "error"
+	if status != 0 {
+		label = strconv.Itoa(status)
+	}
+	m.overwatchStatus.WithLabelValues(endpoint, label).Inc()
+}
+
+// ObserveCache records a cache lookup
+func (m *Metrics) ObserveCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// ObserveShards records the number of shards, the shards start disconnected
+func (m *Metrics) ObserveShards(count int) {
+	m.shards.Set(float64(count))
+	for shard := range count {
+		m.shardConnected.WithLabelValues(strconv.Itoa(shard)).Set(0)
+	}
+}
+
+// ObserveReconnect records a new connection of a shard to the Discord gateway
+func (m *Metrics) ObserveReconnect(shard int) {
+	m.reconnects.WithLabelValues(strconv.Itoa(shard)).Inc()
+}
+
+// ObserveShardConnected records whether a shard is connected to the Discord gateway
+func (m *Metrics) ObserveShardConnected(shard int, connected bool) {
+	value := 0.0
+	if connected {
+		value = 1
+	}
+	m.shardConnected.WithLabelValues(strconv.Itoa(shard)).Set(value)
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// checkTimeout bounds the readiness checks so a hanging dependency doesn't block the probe
+const checkTimeout = 5 * time.Second
+
+// Check reports whether a dependency of the bot is usable
+type Check func(ctx context.Context) error
+
+// Server exposes /healthz, /readyz and /metrics
+type Server struct {
+	server *http.Server
+	logger *log.Logger
+}
+
+// NewServer creates the HTTP server listening on addr
+// /healthz answers as long as the process runs, /readyz runs every check and fails if one of them does
+func NewServer(addr string, metrics *Metrics, checks map[string]Check, logger *log.Logger) *Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		names := make([]string, 0, len(checks))
+		for name := range checks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		status := http.StatusOK
+		body := ""
+		for _, name := range names {
+			if err := checks[name](ctx); err != nil {
+				status = http.StatusServiceUnavailable
+				body += fmt.Sprintf("%s: %v\n", name, err)
+				continue
+			}
+			body += fmt.Sprintf("%s: ok\n", name)
+		}
+
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	})
+	mux.Handle("GET /metrics", promhttp.HandlerFor(metrics.Registry(), promhttp.HandlerOpts{}))
+
+	return &Server{
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		logger: logger,
+	}
+}
+
+// Start listens in the background, an error is logged if the address can't be used
+func (s *Server) Start() {
+	s.logger.WithField("addr", s.server.Addr).Info("Starting HTTP server for health checks and metrics...")
+
+	go func() {
+		if err := s.server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.WithError(err).Error("HTTP server failed")
+		}
+	}()
+}
+
+// Stop shuts the server down, waiting for the requests in progress until ctx is done
+func (s *Server) Stop(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
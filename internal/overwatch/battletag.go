@@ -0,0 +1,26 @@
+package overwatch
+
+import (
+	"regexp"
+	"strings"
+)
+
+var battleTagRegex = regexp.MustCompile(`^[a-zA-Z0-9]{3,12}#[0-9]{4,5}$`)
+
+// IsValidBattleTag reports whether a BattleTag in display format (Player#1234) is valid
+func IsValidBattleTag(battleTag string) bool {
+	return battleTagRegex.MatchString(battleTag)
+}
+
+// ToAPIFormat converts a BattleTag from "Player#1234" to "Player-1234", the format used by the Overwatch API
+func ToAPIFormat(battleTag string) string {
+	return strings.ReplaceAll(battleTag, "#", "-")
+}
+
+// ToDisplayFormat converts a BattleTag from "Player-1234" to "Player#1234"
+func ToDisplayFormat(battleTag string) string {
+	if i := strings.LastIndex(battleTag, "-"); i >= 0 {
+		return battleTag[:i] + "#" + battleTag[i+1:]
+	}
+	return battleTag
+}
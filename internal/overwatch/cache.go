@@ -0,0 +1,33 @@
+package overwatch
+
+import (
+	"sync"
+	"time"
+)
+
+// cachedValue holds a value fetched from the API until it expires
+type cachedValue[T any] struct {
+	mu      sync.Mutex
+	value   T
+	expires time.Time
+}
+
+// get returns the cached value, or calls fetch and caches its result if the value is missing or expired
+// hit reports whether the cached value was used
+func (c *cachedValue[T]) get(ttl time.Duration, fetch func() (T, error)) (value T, hit bool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Now().Before(c.expires) {
+		return c.value, true, nil
+	}
+
+	value, err = fetch()
+	if err != nil {
+		return value, false, err
+	}
+
+	c.value = value
+	c.expires = time.Now().Add(ttl)
+	return value, false, nil
+}
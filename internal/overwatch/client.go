@@ -1,9 +1,11 @@
 package overwatch
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -11,9 +13,17 @@ import (
 
 // Client is a client for the Overwatch API
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	logger     *log.Logger
+	baseURL     string
+	httpClient  *http.Client
+	gameDataTTL time.Duration
+	observer    Observer
+	logger      *log.Logger
+
+	heroes cachedValue[[]HeroSummary]
+	maps   cachedValue[[]Map]
+
+	// season is the most recent competitive season seen in the player profiles
+	season atomic.Int64
 }
 
 // Endorsement represents a player's endorsement level
@@ -46,6 +56,14 @@ type Competitive struct {
 	Console CompetitivePlatformStats `json:"console"`
 }
 
+// Platform returns the competitive stats of the given platform ("pc" or "console"), defaulting to PC
+func (c Competitive) Platform(platform string) CompetitivePlatformStats {
+	if platform == "console" {
+		return c.Console
+	}
+	return c.PC
+}
+
 // Player represents an Overwatch player
 type Player struct {
 	Name          string      `json:"username"`
@@ -57,34 +75,75 @@ type Player struct {
 }
 
 // NewClient creates a new Overwatch API client
-func NewClient(baseURL string, logger *log.Logger) *Client {
+// Requests time out after timeout, the lists of heroes and maps are cached for gameDataTTL
+func NewClient(baseURL string, timeout, gameDataTTL time.Duration, logger *log.Logger) *Client {
 	return &Client{
 		baseURL: baseURL,
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: timeout,
 		},
-		logger: logger,
+		gameDataTTL: gameDataTTL,
+		observer:    nopObserver{},
+		logger:      logger,
 	}
 }
 
+// Observer records the requests made to the API and the lookups of the caches
+type Observer interface {
+	// ObserveRequest records a request, status is 0 when no response was received
+	ObserveRequest(endpoint string, status int, duration time.Duration)
+	ObserveCache(cache string, hit bool)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) ObserveRequest(string, int, time.Duration) {}
+func (nopObserver) ObserveCache(string, bool)                 {}
+
+// SetObserver records the activity of the client with observer, it must be called before the client is used
+func (c *Client) SetObserver(observer Observer) {
+	c.observer = observer
+}
+
+// observe records a request with the observer
+func (c *Client) observe(endpoint string, resp *http.Response, duration time.Duration) {
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	c.observer.ObserveRequest(endpoint, status, duration)
+}
+
+// get sends a GET request to url, it's cancelled with ctx
+func (c *Client) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient.Do(req)
+}
+
 // GetPlayer retrieves a player's profile from the Overwatch API
-func (c *Client) GetPlayer(battletag string) (*Player, error) {
+func (c *Client) GetPlayer(ctx context.Context, battletag string) (*Player, error) {
+	logger := c.logger.WithContext(ctx)
+
 	url := fmt.Sprintf("%s/players/%s/summary", c.baseURL, battletag)
 
-	c.logger.WithFields(log.Fields{
+	logger.WithFields(log.Fields{
 		"battletag": battletag,
 		"url":       url,
 	}).Info("Fetching player profile from Overwatch API")
 
 	start := time.Now()
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.get(ctx, url)
+	c.observe("player", resp, time.Since(start))
 	if err != nil {
-		c.logger.WithError(err).WithField("url", url).Error("Failed to fetch player profile from Overwatch API")
+		logger.WithError(err).WithField("url", url).Error("Failed to fetch player profile from Overwatch API")
 		return nil, fmt.Errorf("failed to fetch player profile: %w", err)
 	}
 	defer resp.Body.Close()
 
-	c.logger.WithFields(log.Fields{
+	logger.WithFields(log.Fields{
 		"battletag": battletag,
 		"url":       url,
 		"status":    resp.StatusCode,
@@ -92,7 +151,7 @@ func (c *Client) GetPlayer(battletag string) (*Player, error) {
 	}).Debug("Fetched player profile from Overwatch API")
 
 	if resp.StatusCode != http.StatusOK {
-		c.logger.WithFields(log.Fields{
+		logger.WithFields(log.Fields{
 			"battletag": battletag,
 			"status":    resp.StatusCode,
 		}).Warn("Player profile not found in Overwatch API")
@@ -101,14 +160,33 @@ func (c *Client) GetPlayer(battletag string) (*Player, error) {
 
 	var player Player
 	if err := json.NewDecoder(resp.Body).Decode(&player); err != nil {
-		c.logger.WithError(err).WithField("battletag", battletag).Error("Failed to decode player profile from Overwatch API")
+		logger.WithError(err).WithField("battletag", battletag).Error("Failed to decode player profile from Overwatch API")
 		return nil, fmt.Errorf("failed to decode player profile: %w", err)
 	}
 
-	c.logger.WithFields(log.Fields{
-		"name":            player.Name,
+	c.recordSeason(player.Competitive.PC.Season)
+	c.recordSeason(player.Competitive.Console.Season)
+
+	logger.WithFields(log.Fields{
+		"player":          player.Name,
 		"last_updated_at": time.Unix(int64(player.LastUpdatedAt), 0).Format(time.RFC3339),
 	}).Info("Successfully retrieved player profile from Overwatch API")
 
 	return &player, nil
 }
+
+// CurrentSeason returns the most recent competitive season seen in the player profiles, or 0 if none was seen yet
+// The API doesn't expose the current season, it's only known from the profiles of players who played it
+func (c *Client) CurrentSeason() int {
+	return int(c.season.Load())
+}
+
+// recordSeason keeps the most recent competitive season
+func (c *Client) recordSeason(season int) {
+	for {
+		current := c.season.Load()
+		if int64(season) <= current || c.season.CompareAndSwap(current, int64(season)) {
+			return
+		}
+	}
+}
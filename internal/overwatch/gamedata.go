@@ -0,0 +1,123 @@
+package overwatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HeroSummary represents a hero in the list of heroes
+type HeroSummary struct {
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Portrait string `json:"portrait"`
+	Role     string `json:"role"`
+}
+
+// HitPoints represents the health pool of a hero
+type HitPoints struct {
+	Health  int `json:"health"`
+	Armor   int `json:"armor"`
+	Shields int `json:"shields"`
+	Total   int `json:"total"`
+}
+
+// Ability represents an ability of a hero
+type Ability struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+}
+
+// Hero represents the details of a hero
+type Hero struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Portrait    string    `json:"portrait"`
+	Role        string    `json:"role"`
+	Location    string    `json:"location"`
+	HitPoints   HitPoints `json:"hitpoints"`
+	Abilities   []Ability `json:"abilities"`
+}
+
+// Map represents an Overwatch map
+type Map struct {
+	Name        string   `json:"name"`
+	Screenshot  string   `json:"screenshot"`
+	GameModes   []string `json:"gamemodes"`
+	Location    string   `json:"location"`
+	CountryCode string   `json:"country_code"`
+}
+
+// GetHeroes retrieves the list of heroes, cached since it only changes with game patches
+func (c *Client) GetHeroes(ctx context.Context) ([]HeroSummary, error) {
+	heroes, hit, err := c.heroes.get(c.gameDataTTL, func() ([]HeroSummary, error) {
+		var heroes []HeroSummary
+		if err := c.getJSON(ctx, "heroes", "/heroes", &heroes); err != nil {
+			return nil, fmt.Errorf("failed to fetch heroes: %w", err)
+		}
+		return heroes, nil
+	})
+	c.observer.ObserveCache("heroes", hit)
+	return heroes, err
+}
+
+// GetHero retrieves the details of a hero from its key (e.g. "ana")
+func (c *Client) GetHero(ctx context.Context, key string) (*Hero, error) {
+	var hero Hero
+	if err := c.getJSON(ctx, "hero", "/heroes/"+url.PathEscape(key), &hero); err != nil {
+		return nil, fmt.Errorf("failed to fetch hero: %w", err)
+	}
+	return &hero, nil
+}
+
+// GetMaps retrieves the list of maps, cached since it only changes with game patches
+func (c *Client) GetMaps(ctx context.Context) ([]Map, error) {
+	maps, hit, err := c.maps.get(c.gameDataTTL, func() ([]Map, error) {
+		var maps []Map
+		if err := c.getJSON(ctx, "maps", "/maps", &maps); err != nil {
+			return nil, fmt.Errorf("failed to fetch maps: %w", err)
+		}
+		return maps, nil
+	})
+	c.observer.ObserveCache("maps", hit)
+	return maps, err
+}
+
+// getJSON fetches a path of the API and decodes the JSON response into target
+// endpoint names the path in the metrics without the parameters it contains
+func (c *Client) getJSON(ctx context.Context, endpoint, path string, target any) error {
+	url := c.baseURL + path
+	logger := c.logger.WithContext(ctx)
+
+	start := time.Now()
+	resp, err := c.get(ctx, url)
+	c.observe(endpoint, resp, time.Since(start))
+	if err != nil {
+		logger.WithError(err).WithField("url", url).Error("Failed to fetch data from Overwatch API")
+		return err
+	}
+	defer resp.Body.Close()
+
+	logger.WithFields(log.Fields{
+		"url":      url,
+		"status":   resp.StatusCode,
+		"duration": time.Since(start),
+	}).Debug("Fetched data from Overwatch API")
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-200 response: %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		logger.WithError(err).WithField("url", url).Error("Failed to decode data from Overwatch API")
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+// Package presence rotates the status of the bot through templates filled with live values
+package presence
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/borisjacquot/juno/internal/lifecycle"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxLength is the longest status Discord shows
+const MaxLength = 128
+
+// MinInterval is the shortest time a template can be shown, Discord limits how often the status can change
+const MinInterval = 30 * time.Second
+
+// fallback is the status shown when no template can be rendered
+const fallback = "Overwatch | /help"
+
+// placeholderPattern matches the placeholders of a template
+var placeholderPattern = regexp.MustCompile(`\{[^{}]*\}`)
+
+// Placeholders replaced by live values
+const (
+	PlaceholderGuilds  = "{guilds}"
+	PlaceholderPlayers = "{players}"
+	PlaceholderSeason  = "{season}"
+)
+
+// Values are the live values of the templates
+// Players is negative and Season is 0 while they are unknown, the templates using them are skipped
+type Values struct {
+	Guilds  int
+	Players int64
+	Season  int
+}
+
+// Validate checks that a template only contains known placeholders and fits in a status
+func Validate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("template is empty")
+	}
+	for _, placeholder := range placeholderPattern.FindAllString(template, -1) {
+		switch placeholder {
+		case PlaceholderGuilds, PlaceholderPlayers, PlaceholderSeason:
+		default:
+			return fmt.Errorf("unknown placeholder %s in '%s', use %s, %s or %s", placeholder, template, PlaceholderGuilds, PlaceholderPlayers, PlaceholderSeason)
+		}
+	}
+	if utf8.RuneCountInString(template) > MaxLength {
+		return fmt.Errorf("template '%s' is longer than %d characters", template, MaxLength)
+	}
+	return nil
+}
+
+// Render fills a template with values, ok is false when it uses a value that is unknown
+func Render(template string, values Values) (status string, ok bool) {
+	if strings.Contains(template, PlaceholderPlayers) && values.Players < 0 {
+		return "", false
+	}
+	if strings.Contains(template, PlaceholderSeason) && values.Season <= 0 {
+		return "", false
+	}
+
+	status = strings.NewReplacer(
+		PlaceholderGuilds, strconv.Itoa(values.Guilds),
+		PlaceholderPlayers, strconv.FormatInt(values.Players, 10),
+		PlaceholderSeason, strconv.Itoa(values.Season),
+	).Replace(template)
+
+	// a large number can push a status over the limit, Discord would reject it
+	if runes := []rune(status); len(runes) > MaxLength {
+		status = string(runes[:MaxLength])
+	}
+	return status, true
+}
+
+// Manager shows the templates in turn, each one for interval
+// The owner can change the templates at runtime, the changes last until the bot restarts
+type Manager struct {
+	mu        sync.Mutex
+	defaults  []string
+	templates []string
+	index     int
+
+	interval time.Duration
+	values   func(ctx context.Context) Values
+	apply    func(status string)
+	logger   *log.Logger
+
+	stop chan struct{}
+}
+
+// NewManager creates a manager rotating through validated templates
+// values returns the live values, apply sets the status of the bot
+func NewManager(templates []string, interval time.Duration, values func(ctx context.Context) Values, apply func(status string), logger *log.Logger) *Manager {
+	return &Manager{
+		defaults:  slices.Clone(templates),
+		templates: slices.Clone(templates),
+		interval:  interval,
+		values:    values,
+		apply:     apply,
+		logger:    logger,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Start shows the next template every interval until Stop is called
+// The rotations are run in jobs so a shutdown waits for the one in progress
+func (m *Manager) Start(jobs *lifecycle.Group) {
+	m.logger.WithFields(log.Fields{
+		"templates": len(m.Templates()),
+		"interval":  m.interval,
+	}).Info("Starting presence rotation")
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				jobs.Go("presence rotation", m.rotate)
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the rotation
+func (m *Manager) Stop() {
+	close(m.stop)
+}
+
+// Status renders the current template with the live values
+// When it uses an unknown value the next templates are tried, the fallback status is used if none can be rendered
+func (m *Manager) Status(ctx context.Context) string {
+	m.mu.Lock()
+	index := m.index
+	m.mu.Unlock()
+
+	status, _ := m.render(ctx, index)
+	return status
+}
+
+// Templates returns the templates in the order they are shown
+func (m *Manager) Templates() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.templates)
+}
+
+// AddTemplate adds a template at the end of the rotation and shows it right away, unless it uses an unknown value
+func (m *Manager) AddTemplate(ctx context.Context, template string) error {
+	if err := Validate(template); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.templates = append(m.templates, template)
+	m.index = len(m.templates) - 1
+	m.mu.Unlock()
+
+	m.refresh(ctx)
+	return nil
+}
+
+// RemoveTemplate removes the template at a position of the rotation, starting at 1, and returns it
+func (m *Manager) RemoveTemplate(ctx context.Context, position int) (string, error) {
+	m.mu.Lock()
+	if position < 1 || position > len(m.templates) {
+		count := len(m.templates)
+		m.mu.Unlock()
+		return "", fmt.Errorf("there is no template at position %d, the rotation has %d", position, count)
+	}
+
+	removed := m.templates[position-1]
+	m.templates = slices.Delete(m.templates, position-1, position)
+	if m.index >= len(m.templates) {
+		m.index = 0
+	}
+	m.mu.Unlock()
+
+	m.refresh(ctx)
+	return removed, nil
+}
+
+// Reset restores the configured templates
+func (m *Manager) Reset(ctx context.Context) {
+	m.mu.Lock()
+	m.templates = slices.Clone(m.defaults)
+	m.index = 0
+	m.mu.Unlock()
+
+	m.refresh(ctx)
+}
+
+// rotate shows the next template
+func (m *Manager) rotate(ctx context.Context) {
+	m.mu.Lock()
+	next := m.index + 1
+	m.mu.Unlock()
+
+	status, index := m.render(ctx, next)
+
+	m.mu.Lock()
+	m.index = index
+	m.mu.Unlock()
+
+	m.logger.WithField("status", status).Debug("Rotating presence")
+	m.apply(status)
+}
+
+// refresh shows the current template again, after the templates changed
+func (m *Manager) refresh(ctx context.Context) {
+	status := m.Status(ctx)
+	m.logger.WithField("status", status).Info("Presence changed")
+	m.apply(status)
+}
+
+// render renders the first template that can be rendered, starting at index
+// It returns the status and the index of the template it was rendered from
+func (m *Manager) render(ctx context.Context, index int) (string, int) {
+	templates := m.Templates()
+	if len(templates) == 0 {
+		return fallback, 0
+	}
+
+	values := m.values(ctx)
+	for offset := range templates {
+		current := (index + offset) % len(templates)
+		if status, ok := Render(templates[current], values); ok {
+			return status, current
+		}
+	}
+	return fallback, index % len(templates)
+}
@@ -0,0 +1,208 @@
+package transfer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/interaction"
+	"github.com/borisjacquot/juno/internal/overwatch"
+	log "github.com/sirupsen/logrus"
+)
+
+// Format is a file format supported for exports and imports
+type Format string
+
+// Supported formats
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+var csvHeader = []string{"user_id", "battletag"}
+
+// Record is an exported registration, the BattleTag is in display format (Player#1234)
+type Record struct {
+	UserID    string `json:"user_id"`
+	BattleTag string `json:"battletag"`
+}
+
+// RowError is a validation error of a single imported row, rows are numbered from 1
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported format '%s' (expected csv or json)", name)
+	}
+}
+
+// FormatFromFilename guesses the format of a file from its extension
+func FormatFromFilename(filename string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+// Export writes the registrations of a guild to w
+func Export(w io.Writer, format Format, registrations []database.UserRegistration) error {
+	records := make([]Record, 0, len(registrations))
+	for _, registration := range registrations {
+		records = append(records, Record{
+			UserID:    registration.UserID,
+			BattleTag: overwatch.ToDisplayFormat(registration.BattleTag),
+		})
+	}
+
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		for _, record := range records {
+			if err := cw.Write([]string{record.UserID, record.BattleTag}); err != nil {
+				return fmt.Errorf("failed to write CSV row: %w", err)
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(records)
+	default:
+		return fmt.Errorf("unsupported format '%s'", format)
+	}
+}
+
+// Parse reads registrations from r and validates every row
+// Valid records are returned along with the errors of the invalid rows, err is only set when the file can't be read
+func Parse(r io.Reader, format Format) (records []Record, rowErrors []RowError, err error) {
+	var raw []Record
+
+	switch format {
+	case FormatCSV:
+		raw, err = readCSV(r)
+	case FormatJSON:
+		if decodeErr := json.NewDecoder(r).Decode(&raw); decodeErr != nil {
+			err = fmt.Errorf("invalid JSON file: %w", decodeErr)
+		}
+	default:
+		err = fmt.Errorf("unsupported format '%s'", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]int)
+	for n, record := range raw {
+		row := n + 1
+		record.UserID = strings.TrimSpace(record.UserID)
+		record.BattleTag = strings.TrimSpace(record.BattleTag)
+
+		if err := validate(&record); err != nil {
+			rowErrors = append(rowErrors, RowError{Row: row, Err: err})
+			continue
+		}
+
+		if previous, exists := seen[record.UserID]; exists {
+			rowErrors = append(rowErrors, RowError{Row: row, Err: fmt.Errorf("user %s is already listed on row %d", record.UserID, previous)})
+			continue
+		}
+		seen[record.UserID] = row
+
+		records = append(records, record)
+	}
+
+	return records, rowErrors, nil
+}
+
+// readCSV reads the records of a CSV file, the header row is required
+func readCSV(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(csvHeader)
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("empty CSV file")
+		}
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	for n, column := range csvHeader {
+		if !strings.EqualFold(strings.TrimSpace(header[n]), column) {
+			return nil, fmt.Errorf("invalid CSV header, expected %s", strings.Join(csvHeader, ","))
+		}
+	}
+
+	var records []Record
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV file: %w", err)
+		}
+		records = append(records, Record{UserID: row[0], BattleTag: row[1]})
+	}
+
+	return records, nil
+}
+
+// validate checks a record and normalizes its BattleTag to display format
+func validate(record *Record) error {
+	if !interaction.IsSnowflake(record.UserID) {
+		return fmt.Errorf("invalid Discord user ID '%s'", record.UserID)
+	}
+
+	// accept BattleTags exported in API format (Player-1234) too
+	if !strings.Contains(record.BattleTag, "#") {
+		record.BattleTag = overwatch.ToDisplayFormat(record.BattleTag)
+	}
+
+	if !overwatch.IsValidBattleTag(record.BattleTag) {
+		return fmt.Errorf("invalid BattleTag '%s' for user %s, expected Player#1234", record.BattleTag, record.UserID)
+	}
+
+	return nil
+}
+
+// Import registers the records in a guild with a single call to RegisterUsers and returns the number of users
+// A user listed more than once gets the BattleTag of their last record, the records must have been validated by Parse
+// Stores apply the registrations atomically, nothing is imported when an error is returned
+func Import(ctx context.Context, store database.RegistrationStore, guildID string, records []Record, lgr *log.Logger) (int, error) {
+	lgr.WithFields(log.Fields{
+		"guild_id": guildID,
+		"count":    len(records),
+	}).Info("Importing registrations")
+
+	battleTags := make(map[string]string, len(records))
+	for _, record := range records {
+		battleTags[record.UserID] = overwatch.ToAPIFormat(record.BattleTag)
+	}
+
+	if err := store.RegisterUsers(ctx, guildID, battleTags); err != nil {
+		return 0, fmt.Errorf("failed to import registrations: %w", err)
+	}
+
+	return len(battleTags), nil
+}
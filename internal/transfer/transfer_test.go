@@ -0,0 +1,213 @@
+package transfer_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/borisjacquot/juno/internal/database"
+	"github.com/borisjacquot/juno/internal/database/memory"
+	"github.com/borisjacquot/juno/internal/transfer"
+	log "github.com/sirupsen/logrus"
+)
+
+const guildID = "100000000000000001"
+
+func TestRoundTrip(t *testing.T) {
+	registrations := []database.UserRegistration{
+		{GuildID: guildID, UserID: "200000000000000001", BattleTag: "Player-1234"},
+		{GuildID: guildID, UserID: "200000000000000002", BattleTag: "Joueur-56789"},
+	}
+	want := []transfer.Record{
+		{UserID: "200000000000000001", BattleTag: "Player#1234"},
+		{UserID: "200000000000000002", BattleTag: "Joueur#56789"},
+	}
+
+	for _, format := range []transfer.Format{transfer.FormatCSV, transfer.FormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := transfer.Export(&buf, format, registrations); err != nil {
+				t.Fatalf("failed to export: %v", err)
+			}
+
+			records, rowErrors, err := transfer.Parse(&buf, format)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if len(rowErrors) > 0 {
+				t.Errorf("row errors = %v, want none", rowErrors)
+			}
+			if !slices.Equal(records, want) {
+				t.Errorf("records = %v, want %v", records, want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      transfer.Format
+		input       string
+		wantRecords []transfer.Record
+		wantErrors  []string
+		wantErr     string
+	}{
+		{
+			name:        "API format and spaces",
+			format:      transfer.FormatCSV,
+			input:       "user_id,battletag\n 200000000000000001 , Player-1234\n",
+			wantRecords: []transfer.Record{{UserID: "200000000000000001", BattleTag: "Player#1234"}},
+		},
+		{
+			name:       "invalid user ID",
+			format:     transfer.FormatCSV,
+			input:      "user_id,battletag\n12345,Player#1234\n",
+			wantErrors: []string{"row 1: invalid Discord user ID '12345'"},
+		},
+		{
+			name:   "invalid BattleTags",
+			format: transfer.FormatJSON,
+			input: `[
+				{"user_id": "200000000000000001", "battletag": "Player"},
+				{"user_id": "200000000000000002", "battletag": "P#1234"},
+				{"user_id": "200000000000000003", "battletag": "Player#12"},
+				{"user_id": "200000000000000004", "battletag": "Player#1234"}
+			]`,
+			wantRecords: []transfer.Record{{UserID: "200000000000000004", BattleTag: "Player#1234"}},
+			wantErrors: []string{
+				"row 1: invalid BattleTag 'Player'",
+				"row 2: invalid BattleTag 'P#1234'",
+				"row 3: invalid BattleTag 'Player#12'",
+			},
+		},
+		{
+			name:        "duplicate user",
+			format:      transfer.FormatCSV,
+			input:       "user_id,battletag\n200000000000000001,Player#1234\n200000000000000001,Other#5678\n",
+			wantRecords: []transfer.Record{{UserID: "200000000000000001", BattleTag: "Player#1234"}},
+			wantErrors:  []string{"row 2: user 200000000000000001 is already listed on row 1"},
+		},
+		{
+			name:    "missing header",
+			format:  transfer.FormatCSV,
+			input:   "200000000000000001,Player#1234\n",
+			wantErr: "invalid CSV header",
+		},
+		{
+			name:    "empty CSV",
+			format:  transfer.FormatCSV,
+			input:   "",
+			wantErr: "empty CSV file",
+		},
+		{
+			name:    "missing column",
+			format:  transfer.FormatCSV,
+			input:   "user_id,battletag\n200000000000000001\n",
+			wantErr: "invalid CSV file",
+		},
+		{
+			name:    "invalid JSON",
+			format:  transfer.FormatJSON,
+			input:   `{"user_id": "200000000000000001"}`,
+			wantErr: "invalid JSON file",
+		},
+		{
+			name:    "unsupported format",
+			format:  transfer.Format("xml"),
+			input:   "<registrations/>",
+			wantErr: "unsupported format 'xml'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, rowErrors, err := transfer.Parse(strings.NewReader(tt.input), tt.format)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !slices.Equal(records, tt.wantRecords) {
+				t.Errorf("records = %v, want %v", records, tt.wantRecords)
+			}
+			if len(rowErrors) != len(tt.wantErrors) {
+				t.Fatalf("row errors = %v, want %q", rowErrors, tt.wantErrors)
+			}
+			for n, rowError := range rowErrors {
+				if !strings.HasPrefix(rowError.Error(), tt.wantErrors[n]) {
+					t.Errorf("row error %d = %q, want %q", n, rowError.Error(), tt.wantErrors[n])
+				}
+			}
+		})
+	}
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     transfer.Format
+		wantErr  bool
+	}{
+		{"registrations.csv", transfer.FormatCSV, false},
+		{"registrations.JSON", transfer.FormatJSON, false},
+		{"registrations.txt", "", true},
+		{"registrations", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			format, err := transfer.FormatFromFilename(tt.filename)
+			if (err != nil) != tt.wantErr || format != tt.want {
+				t.Errorf("FormatFromFilename(%q) = %q, %v, want %q", tt.filename, format, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+
+	if err := store.RegisterUser(ctx, guildID, "200000000000000001", "Old-1111"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	records, _, err := transfer.Parse(strings.NewReader("user_id,battletag\n200000000000000001,Player#1234\n200000000000000002,Other-5678\n"), transfer.FormatCSV)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	imported, err := transfer.Import(ctx, store, guildID, records, logger)
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if imported != 2 {
+		t.Errorf("imported %d users, want 2", imported)
+	}
+
+	// the registrations are stored in API format and replace the previous ones
+	want := map[string]string{
+		"200000000000000001": "Player-1234",
+		"200000000000000002": "Other-5678",
+	}
+	for userID, battleTag := range want {
+		got, err := store.GetUserBattleTag(ctx, guildID, userID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != battleTag {
+			t.Errorf("BattleTag of %s = %q, want %q", userID, got, battleTag)
+		}
+	}
+}